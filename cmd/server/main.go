@@ -3,12 +3,15 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/azevedoguigo/thermosync-api/config"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
 	"github.com/azevedoguigo/thermosync-api/internal/handler"
 	authMiddleware "github.com/azevedoguigo/thermosync-api/internal/middleware"
 	"github.com/azevedoguigo/thermosync-api/internal/repository"
 	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
 	"github.com/azevedoguigo/thermosync-api/internal/websocket"
 
 	"github.com/go-chi/chi/middleware"
@@ -19,22 +22,65 @@ import (
 func main() {
 	db := config.InitDB()
 
+	tokenService, err := token.NewService(config.LoadTokenConfig())
+	if err != nil {
+		log.Fatalf("Invalid token configuration: %s", err)
+	}
+	revocationRepo := repository.NewRevocationRepository(db)
+
 	userRepo := repository.NewUserRepository(db)
-	userService := service.NewUserService(userRepo)
+	apiTokenRepo := repository.NewAPITokenRepository(db)
+	apiTokenService := service.NewAPITokenService(userRepo, apiTokenRepo)
+	apiTokenHandler := handler.NewAPITokenHandler(apiTokenService)
+
+	// requireAuth only accepts access tokens, allowAPITokens also accepts
+	// personal API tokens and guards the routes scripts need.
+	requireAuth := authMiddleware.AuthMiddleware(tokenService, revocationRepo, nil)
+	allowAPITokens := authMiddleware.AuthMiddleware(tokenService, revocationRepo, apiTokenService)
+
+	sessionRepo := repository.NewSessionRepository(db)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	recoveryCodeRepo := repository.NewRecoveryCodeRepository(db)
+	authService := service.NewAuthService(userRepo, sessionRepo, refreshTokenRepo, passwordResetRepo, auditRepo, recoveryCodeRepo, apiTokenRepo, revocationRepo, tokenService, config.LoadMailer(), config.LoadAuthConfig())
+	authHandler := handler.NewAuthHandler(authService)
+
+	identityRepo := repository.NewExternalIdentityRepository(db)
+	oidcService := service.NewOIDCService(userRepo, identityRepo, config.LoadOIDCProviders(), authService)
+	oidcHandler := handler.NewOIDCHandler(oidcService)
+
+	hub := websocket.NewHub(config.LoadWebsocketConfig())
+
+	userService := service.NewUserService(userRepo, authService, authService, hub)
+	adminService := service.NewAdminService(userRepo, auditRepo, authService)
+	adminHandler := handler.NewAdminHandler(adminService)
 	userHandler := handler.NewUserHandler(userService)
+	jwksHandler := handler.NewJWKSHandler(tokenService)
+
+	deviceRepo := repository.NewDeviceRepository(db)
+	homeRepo := repository.NewHomeRepository(db)
+	deviceService := service.NewDeviceService(deviceRepo, homeRepo, hub)
+	deviceHandler := handler.NewDeviceHandler(deviceService)
+
+	homeService := service.NewHomeService(homeRepo, deviceRepo, userRepo)
+	homeHandler := handler.NewHomeHandler(homeService)
 
-	authHandler := handler.NewAuthHandler(userService)
+	readingRepo := repository.NewReadingRepository(db)
+	readingService := service.NewReadingService(readingRepo, deviceRepo, userRepo, homeService)
+	readingHandler := handler.NewReadingHandler(readingService)
+	websocketHandler := handler.NewWebsocketHandler(hub, userService, deviceService, homeService, readingService)
 
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
-	router.Use(middleware.RealIP)
+	router.Use(authMiddleware.RealIP(config.LoadTrustedProxies()))
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: true,
@@ -43,21 +89,114 @@ func main() {
 
 	router.Route("/users", func(r chi.Router) {
 		r.Post("/", userHandler.CreateUser)
-		r.With(authMiddleware.AuthMiddleware).Get("/{id}", userHandler.FindUserByID)
+		r.With(allowAPITokens).Get("/me", userHandler.GetMe)
+		r.With(requireAuth).Patch("/me", userHandler.UpdateMe)
+		r.With(requireAuth).Delete("/me", userHandler.DeleteMe)
+		r.With(requireAuth).Post("/me/password", userHandler.ChangePassword)
+		r.With(requireAuth).Get("/{id}", userHandler.FindUserByID)
+		// Deprecated in favour of PATCH /me, kept for existing clients.
+		r.With(requireAuth).Put("/me/preferences", userHandler.UpdatePreferences)
+		r.With(requireAuth).Get("/me/tokens", apiTokenHandler.ListTokens)
+		r.With(requireAuth).Post("/me/tokens", apiTokenHandler.CreateToken)
+		r.With(requireAuth).Delete("/me/tokens/{id}", apiTokenHandler.RevokeToken)
 	})
 
+	router.Get("/.well-known/jwks.json", jwksHandler.GetJWKS)
+
 	router.Route("/auth", func(r chi.Router) {
 		r.Post("/", authHandler.Login)
+		r.Post("/refresh", authHandler.Refresh)
+		r.Post("/verify-email", authHandler.VerifyEmail)
+		r.Post("/verify-email/resend", authHandler.ResendVerification)
+		r.Post("/forgot-password", authHandler.ForgotPassword)
+		r.Post("/reset-password", authHandler.ResetPassword)
+		r.Post("/mfa/verify", authHandler.VerifyMFA)
+		r.Post("/oidc/{provider}/start", oidcHandler.StartLogin)
+		r.Post("/oidc/{provider}/callback", oidcHandler.CompleteLogin)
+		r.With(requireAuth).Post("/mfa/totp", authHandler.EnrollTOTP)
+		r.With(requireAuth).Post("/mfa/totp/confirm", authHandler.ConfirmTOTP)
+		r.With(requireAuth).Delete("/mfa/totp", authHandler.DisableTOTP)
+		r.With(requireAuth).Post("/logout", authHandler.Logout)
+		r.With(requireAuth).Post("/logout-all", authHandler.LogoutAll)
+		r.With(requireAuth).Get("/sessions", authHandler.ListSessions)
 	})
 
-	router.Get("/ws", handler.Websocket)
+	canReadDevices := authMiddleware.RequirePermission(domain.PermissionDevicesRead)
+	canWriteDevices := authMiddleware.RequirePermission(domain.PermissionDevicesWrite)
+	canReadReadings := authMiddleware.RequirePermission(domain.PermissionReadingsRead)
 
-	go websocket.HandleMessages()
+	router.Route("/devices", func(r chi.Router) {
+		r.Use(allowAPITokens)
+		r.With(canWriteDevices).Post("/", deviceHandler.CreateDevice)
+		r.With(canReadDevices).Get("/", deviceHandler.ListDevices)
+		r.With(canReadDevices).Get("/{id}", deviceHandler.FindDeviceByID)
+		r.With(canWriteDevices).Patch("/{id}", deviceHandler.UpdateDevice)
+		r.With(canWriteDevices).Delete("/{id}", deviceHandler.RetireDevice)
+		r.With(canWriteDevices).Post("/{id}/secret", deviceHandler.RotateDeviceSecret)
+	})
+
+	router.Route("/homes", func(r chi.Router) {
+		r.Use(allowAPITokens)
+		r.With(canWriteDevices).Post("/", homeHandler.CreateHome)
+		r.With(canReadDevices).Get("/", homeHandler.ListHomes)
+		r.With(canReadDevices).Get("/{id}", homeHandler.FindHomeByID)
+		r.With(canWriteDevices).Patch("/{id}", homeHandler.UpdateHome)
+		r.With(canWriteDevices).Delete("/{id}", homeHandler.DeleteHome)
+		r.With(canWriteDevices).Post("/{id}/rooms", homeHandler.CreateRoom)
+		r.With(canReadDevices).Get("/{id}/rooms", homeHandler.ListRooms)
+		r.With(canReadDevices).Get("/{id}/members", homeHandler.ListMembers)
+		r.With(canWriteDevices).Put("/{id}/members", homeHandler.SetMember)
+		r.With(canWriteDevices).Delete("/{id}/members/{userID}", homeHandler.RemoveMember)
+	})
+
+	router.Route("/rooms", func(r chi.Router) {
+		r.Use(allowAPITokens)
+		r.With(canReadDevices).Get("/{id}", homeHandler.FindRoomByID)
+		r.With(canWriteDevices).Patch("/{id}", homeHandler.UpdateRoom)
+		r.With(canWriteDevices).Delete("/{id}", homeHandler.DeleteRoom)
+		r.With(canReadDevices).Get("/{id}/devices", homeHandler.ListRoomDevices)
+		r.With(canWriteDevices).Put("/{id}/devices/{deviceID}", homeHandler.AssignDevice)
+		r.With(canWriteDevices).Delete("/{id}/devices/{deviceID}", homeHandler.UnassignDevice)
+	})
+
+	router.Route("/readings", func(r chi.Router) {
+		r.Use(allowAPITokens, canReadReadings)
+		r.Get("/", readingHandler.GetReadingHistory)
+		r.Get("/current", readingHandler.GetCurrentReadings)
+	})
+
+	router.Route("/admin/users", func(r chi.Router) {
+		r.Use(requireAuth, authMiddleware.RequirePermission(domain.PermissionUsersManage))
+		r.Get("/", adminHandler.ListUsers)
+		r.Get("/{id}", adminHandler.FindUser)
+		r.Put("/{id}/role", adminHandler.SetRole)
+		r.Post("/{id}/logout-all", adminHandler.RevokeSessions)
+		r.Delete("/{id}", adminHandler.DeleteUser)
+	})
+
+	router.With(allowAPITokens, canReadReadings).Get("/ws", websocketHandler.Websocket)
+	router.Get("/ws/devices", websocketHandler.DeviceWebsocket)
+
+	go hub.Run()
+	go pruneExpired(revocationRepo, identityRepo)
 
 	log.Println("Server is running in port: 3000")
 
-	err := http.ListenAndServe(":3000", router)
+	err = http.ListenAndServe(":3000", router)
 	if err != nil {
 		log.Fatalf("Error to start server: %s", err)
 	}
 }
+
+// pruneExpired drops revocations whose tokens have expired anyway and
+// abandoned "Sign in with..." logins.
+func pruneExpired(revocationRepo repository.RevocationRepository, identityRepo repository.ExternalIdentityRepository) {
+	for range time.Tick(time.Hour) {
+		if err := revocationRepo.DeleteExpired(time.Now()); err != nil {
+			log.Printf("Failed to prune token revocations: %s", err)
+		}
+		if err := identityRepo.DeleteExpiredLoginStates(time.Now()); err != nil {
+			log.Printf("Failed to prune OIDC login states: %s", err)
+		}
+	}
+}
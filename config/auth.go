@@ -0,0 +1,27 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+)
+
+// LoadAuthConfig reads the login session settings from the environment.
+func LoadAuthConfig() service.AuthConfig {
+	cfg := service.DefaultAuthConfig()
+
+	cfg.RefreshTokenTTL = durationEnv("REFRESH_TOKEN_TTL", cfg.RefreshTokenTTL)
+	cfg.RequireVerifiedEmail = boolEnv("REQUIRE_VERIFIED_EMAIL", cfg.RequireVerifiedEmail)
+	cfg.EmailVerificationTTL = durationEnv("EMAIL_VERIFICATION_TTL", cfg.EmailVerificationTTL)
+	cfg.PasswordResetTTL = durationEnv("PASSWORD_RESET_TTL", cfg.PasswordResetTTL)
+	cfg.AccountThrottle.MaxFailures = intEnv("LOGIN_MAX_FAILURES", cfg.AccountThrottle.MaxFailures)
+	cfg.IPThrottle.MaxFailures = intEnv("LOGIN_IP_MAX_FAILURES", cfg.IPThrottle.MaxFailures)
+	cfg.AccountThrottle.Lockout = durationEnv("LOGIN_LOCKOUT", cfg.AccountThrottle.Lockout)
+	cfg.AccountThrottle.MaxLockout = durationEnv("LOGIN_MAX_LOCKOUT", cfg.AccountThrottle.MaxLockout)
+	cfg.MFAChallengeTTL = durationEnv("MFA_CHALLENGE_TTL", cfg.MFAChallengeTTL)
+	cfg.TOTPIssuer = stringEnv("TOTP_ISSUER", cfg.TOTPIssuer)
+	cfg.RecentLoginWindow = durationEnv("RECENT_LOGIN_WINDOW", cfg.RecentLoginWindow)
+	cfg.AppURL = strings.TrimSuffix(stringEnv("APP_URL", cfg.AppURL), "/")
+
+	return cfg
+}
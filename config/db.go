@@ -48,7 +48,22 @@ func InitDB() *gorm.DB {
 		log.Fatal("Failed to connect database:", err.Error())
 	}
 
-	db.AutoMigrate(&domain.User{})
+	// Accounts created before email verification existed never got a
+	// link, so they are treated as verified once, when the column is
+	// added. Accounts signing up afterwards must verify as usual.
+	backfillVerified := db.Migrator().HasTable(&domain.User{}) &&
+		!db.Migrator().HasColumn(&domain.User{}, "EmailVerifiedAt")
+
+	db.AutoMigrate(&domain.User{}, &domain.Device{}, &domain.Reading{}, &domain.RefreshToken{}, &domain.Session{}, &domain.RevokedToken{}, &domain.PasswordResetToken{}, &domain.AuditEvent{}, &domain.RecoveryCode{}, &domain.ExternalIdentity{}, &domain.OIDCLoginState{}, &domain.APIToken{}, &domain.Home{}, &domain.Room{}, &domain.HomeMember{})
+
+	if backfillVerified {
+		err := db.Model(&domain.User{}).
+			Where("email_verified_at IS NULL").
+			Update("email_verified_at", gorm.Expr("created_at")).Error
+		if err != nil {
+			log.Fatal("Failed to mark existing users as verified:", err.Error())
+		}
+	}
 
 	return db
 }
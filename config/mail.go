@@ -0,0 +1,36 @@
+package config
+
+import (
+	"log"
+	"os"
+
+	"github.com/azevedoguigo/thermosync-api/internal/mail"
+)
+
+// LoadMailer builds the mailer selected by MAIL_DRIVER: "smtp" sends
+// through SMTP_HOST, "file" (the default) writes .eml files to MAIL_DIR for
+// local development.
+func LoadMailer() mail.Mailer {
+	from := stringEnv("MAIL_FROM", "ThermoSync <no-reply@thermosync.local>")
+
+	switch driver := stringEnv("MAIL_DRIVER", "file"); driver {
+	case "smtp":
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     stringEnv("SMTP_PORT", "587"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: secretEnv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	case "file":
+		mailer, err := mail.NewFileMailer(stringEnv("MAIL_DIR", "mail"), from)
+		if err != nil {
+			log.Panicf("Failed to create MAIL_DIR: %s", err)
+		}
+		return mailer
+	default:
+		log.Panicf("Unknown MAIL_DRIVER %q", driver)
+	}
+
+	return nil
+}
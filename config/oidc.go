@@ -0,0 +1,44 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"github.com/azevedoguigo/thermosync-api/internal/oidc"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+)
+
+// LoadOIDCProviders reads the "Sign in with..." providers from the
+// environment. OIDC_PROVIDERS lists their names, e.g. "google,apple", and
+// each is configured by OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID,
+// OIDC_<NAME>_CLIENT_SECRET, OIDC_<NAME>_REDIRECT_URL and optionally
+// OIDC_<NAME>_SCOPES.
+func LoadOIDCProviders() map[string]service.OIDCProvider {
+	providers := make(map[string]service.OIDCProvider)
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		provider, err := oidc.NewProvider(oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: secretEnv(prefix + "CLIENT_SECRET"),
+			RedirectURL:  os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		}, nil)
+		if err != nil {
+			log.Panicf("Invalid OIDC provider configuration: %s", err)
+		}
+
+		providers[name] = provider
+	}
+
+	return providers
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"log"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+// LoadTrustedProxies reads TRUSTED_PROXIES, comma separated addresses or
+// CIDR ranges of the reverse proxies whose X-Forwarded-For and X-Real-IP
+// headers are believed. Without it the peer address is always used.
+func LoadTrustedProxies() []netip.Prefix {
+	var prefixes []netip.Prefix
+
+	for _, value := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				log.Panicf("Invalid TRUSTED_PROXIES entry %q: %s", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			log.Panicf("Invalid TRUSTED_PROXIES entry %q: %s", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+)
+
+// LoadTokenConfig reads the JWT settings from the environment.
+//
+// JWT_KEYS holds HS256 secrets as comma or newline separated kid:secret
+// pairs, JWT_KEYS_FILE can point to a file with the same content instead
+// (e.g. a Docker or Kubernetes secret). JWT_KEYS_DIR holds RS256/EdDSA keys
+// as <kid>.pem files: private keys can sign, public keys are retired keys
+// that are still published in the JWKS.
+//
+// New tokens are signed with JWT_SIGNING_KEY_ID, or the first key when
+// unset, so a key is rotated by adding a new one, switching
+// JWT_SIGNING_KEY_ID to it, replacing the old private key by its public
+// key and removing it once the tokens it signed have expired.
+func LoadTokenConfig() token.Config {
+	cfg := token.DefaultConfig()
+
+	cfg.Issuer = stringEnv("JWT_ISSUER", cfg.Issuer)
+	cfg.TTL = durationEnv("JWT_TTL", cfg.TTL)
+	cfg.Leeway = durationEnv("JWT_LEEWAY", cfg.Leeway)
+	cfg.SigningKeyID = os.Getenv("JWT_SIGNING_KEY_ID")
+
+	for _, entry := range strings.FieldsFunc(secretEnv("JWT_KEYS"), func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok {
+			log.Panicln("JWT_KEYS entries must be formatted as kid:secret")
+		}
+
+		cfg.Keys = append(cfg.Keys, token.Key{
+			ID:     strings.TrimSpace(id),
+			Secret: []byte(strings.TrimSpace(secret)),
+		})
+	}
+
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		cfg.Keys = append(cfg.Keys, loadPEMKeys(dir)...)
+	}
+
+	return cfg
+}
+
+func loadPEMKeys(dir string) []token.Key {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		log.Panicf("Failed to list JWT_KEYS_DIR: %s", err)
+	}
+
+	keys := make([]token.Key, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Panicf("Failed to read %s: %s", path, err)
+		}
+
+		key, err := token.ParsePEMKey(strings.TrimSuffix(filepath.Base(path), ".pem"), content)
+		if err != nil {
+			log.Panicln(err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// secretEnv reads key from the file named by key_FILE when set, otherwise
+// from key itself.
+func secretEnv(key string) string {
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return os.Getenv(key)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		log.Panicf("Failed to read %s_FILE: %s", key, err)
+	}
+
+	return strings.TrimSpace(string(content))
+}
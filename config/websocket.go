@@ -0,0 +1,28 @@
+package config
+
+import (
+	"os"
+	"strings"
+
+	"github.com/azevedoguigo/thermosync-api/internal/websocket"
+)
+
+// LoadWebsocketConfig reads the websocket tuning from the environment,
+// anything unset or invalid keeps its default.
+func LoadWebsocketConfig() websocket.Config {
+	cfg := websocket.DefaultConfig()
+
+	cfg.WriteWait = durationEnv("WS_WRITE_TIMEOUT", cfg.WriteWait)
+	cfg.PongWait = durationEnv("WS_PONG_TIMEOUT", cfg.PongWait)
+	cfg.PingPeriod = durationEnv("WS_PING_INTERVAL", cfg.PingPeriod)
+	cfg.MaxMessageSize = int64(intEnv("WS_MAX_MESSAGE_SIZE", int(cfg.MaxMessageSize)))
+	cfg.SendBufferSize = intEnv("WS_SEND_BUFFER_SIZE", cfg.SendBufferSize)
+
+	for _, origin := range strings.Split(os.Getenv("WS_ALLOWED_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			cfg.AllowedOrigins = append(cfg.AllowedOrigins, origin)
+		}
+	}
+
+	return cfg
+}
@@ -0,0 +1,19 @@
+package contract
+
+type ListUsersQueryDTO struct {
+	// Email filters users whose email contains it.
+	Email string
+	Page  int `validate:"min=1"`
+	Limit int `validate:"min=1,max=100"`
+}
+
+type UserPageDTO struct {
+	Users []UserResponseDTO `json:"users"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+	Total int64             `json:"total"`
+}
+
+type SetRoleDTO struct {
+	Role string `json:"role" validate:"required,oneof=admin member viewer"`
+}
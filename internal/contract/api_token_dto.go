@@ -0,0 +1,42 @@
+package contract
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+type NewAPITokenDTO struct {
+	Name   string   `json:"name" validate:"required,max=50"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=devices:read devices:write readings:read"`
+	// ExpiresAt is optional, tokens without it work until revoked.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APITokenResponseDTO struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// APITokenCreatedResponseDTO is only returned when a token is created, the
+// token can't be recovered afterwards.
+type APITokenCreatedResponseDTO struct {
+	APITokenResponseDTO
+	Token string `json:"token"`
+}
+
+func NewAPITokenResponseDTO(apiToken *domain.APIToken) APITokenResponseDTO {
+	return APITokenResponseDTO{
+		ID:         apiToken.ID,
+		Name:       apiToken.Name,
+		Scopes:     apiToken.ScopeList(),
+		CreatedAt:  apiToken.CreatedAt,
+		ExpiresAt:  apiToken.ExpiresAt,
+		LastUsedAt: apiToken.LastUsedAt,
+	}
+}
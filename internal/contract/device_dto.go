@@ -0,0 +1,58 @@
+package contract
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+type NewDeviceDTO struct {
+	Name   string `json:"name" validate:"required,min=2,max=50"`
+	Room   string `json:"room" validate:"max=50"`
+	Serial string `json:"serial" validate:"required,max=64"`
+
+	TemperatureUnit string `json:"temperature_unit" validate:"omitempty,oneof=C F K"`
+}
+
+type UpdateDeviceDTO struct {
+	Name *string `json:"name" validate:"omitnil,min=2,max=50"`
+	Room *string `json:"room" validate:"omitnil,max=50"`
+
+	TemperatureUnit *string `json:"temperature_unit" validate:"omitnil,oneof=C F K"`
+}
+
+type DeviceResponseDTO struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Room       string     `json:"room"`
+	RoomID     *uuid.UUID `json:"room_id"`
+	Serial     string     `json:"serial"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+
+	TemperatureUnit string `json:"temperature_unit"`
+}
+
+// DeviceCredentialsResponseDTO is only returned when a device secret is
+// issued, the secret can't be recovered afterwards.
+type DeviceCredentialsResponseDTO struct {
+	DeviceResponseDTO
+	Secret string `json:"secret"`
+}
+
+func NewDeviceResponseDTO(device *domain.Device) DeviceResponseDTO {
+	return DeviceResponseDTO{
+		ID:         device.ID,
+		Name:       device.Name,
+		Room:       device.Room,
+		RoomID:     device.RoomID,
+		Serial:     device.Serial,
+		CreatedAt:  device.CreatedAt,
+		LastSeenAt: device.LastSeenAt,
+		RetiredAt:  device.RetiredAt,
+
+		TemperatureUnit: device.TemperatureUnit,
+	}
+}
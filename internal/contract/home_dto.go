@@ -0,0 +1,75 @@
+package contract
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+type NewHomeDTO struct {
+	Name string `json:"name" validate:"required,min=2,max=50"`
+}
+
+type UpdateHomeDTO struct {
+	Name *string `json:"name" validate:"omitnil,min=2,max=50"`
+}
+
+type HomeResponseDTO struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewHomeResponseDTO(home *domain.Home) HomeResponseDTO {
+	return HomeResponseDTO{
+		ID:        home.ID,
+		Name:      home.Name,
+		CreatedAt: home.CreatedAt,
+	}
+}
+
+type NewRoomDTO struct {
+	Name string `json:"name" validate:"required,min=2,max=50"`
+}
+
+type UpdateRoomDTO struct {
+	Name *string `json:"name" validate:"omitnil,min=2,max=50"`
+}
+
+type RoomResponseDTO struct {
+	ID        uuid.UUID `json:"id"`
+	HomeID    uuid.UUID `json:"home_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewRoomResponseDTO(room *domain.Room) RoomResponseDTO {
+	return RoomResponseDTO{
+		ID:        room.ID,
+		HomeID:    room.HomeID,
+		Name:      room.Name,
+		CreatedAt: room.CreatedAt,
+	}
+}
+
+// SetHomeMemberDTO shares a home with the user registered under Email, or
+// changes the role of someone it is already shared with.
+type SetHomeMemberDTO struct {
+	Email string `json:"email" validate:"required,email"`
+	Role  string `json:"role" validate:"required,oneof=admin member viewer"`
+}
+
+type HomeMemberResponseDTO struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewHomeMemberResponseDTO(member *domain.HomeMember) HomeMemberResponseDTO {
+	return HomeMemberResponseDTO{
+		UserID:    member.UserID,
+		Role:      member.Role,
+		CreatedAt: member.CreatedAt,
+	}
+}
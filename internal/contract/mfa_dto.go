@@ -0,0 +1,35 @@
+package contract
+
+// MFAChallengeDTO is returned by a login with the right password when the
+// user has two-factor authentication enabled. MFAToken is exchanged for
+// the access and refresh tokens together with a TOTP or recovery code.
+type MFAChallengeDTO struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+type VerifyMFADTO struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	// Code is a TOTP code or a recovery code.
+	Code string `json:"code" validate:"required"`
+}
+
+type TOTPEnrollmentDTO struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// URI shown as a QR code to authenticator apps.
+	URI string `json:"otpauth_uri"`
+}
+
+type ConfirmTOTPDTO struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTOTPDTO struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type RecoveryCodesDTO struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
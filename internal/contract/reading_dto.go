@@ -0,0 +1,84 @@
+package contract
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type MetricValueDTO struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+type NewReadingDTO struct {
+	DeviceID   uuid.UUID
+	Metrics    map[string]MetricValueDTO `validate:"required,min=1,max=10"`
+	RecordedAt time.Time
+}
+
+// ReadingHistoryQueryDTO asks for the history of one device, or of every
+// device in a room or a home, exactly one of the IDs is set.
+type ReadingHistoryQueryDTO struct {
+	DeviceID uuid.UUID
+	RoomID   uuid.UUID
+	HomeID   uuid.UUID
+	Metric   string    `validate:"required,oneof=temperature humidity pressure co2 battery"`
+	From     time.Time `validate:"required"`
+	To       time.Time `validate:"required,gtfield=From"`
+	Bucket   string    `validate:"required,oneof=1m 5m 1h 1d"`
+}
+
+type ReadingBucketDTO struct {
+	Start time.Time `json:"start"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Avg   float64   `json:"avg"`
+	Count int64     `json:"count"`
+}
+
+type ReadingHistoryDTO struct {
+	DeviceID *uuid.UUID         `json:"device_id,omitempty"`
+	RoomID   *uuid.UUID         `json:"room_id,omitempty"`
+	HomeID   *uuid.UUID         `json:"home_id,omitempty"`
+	Metric   string             `json:"metric"`
+	Unit     string             `json:"unit"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Bucket   string             `json:"bucket"`
+	Buckets  []ReadingBucketDTO `json:"buckets"`
+}
+
+// CurrentReadingsQueryDTO asks for the latest readings of the devices in a
+// room or a home, exactly one of the IDs is set.
+type CurrentReadingsQueryDTO struct {
+	RoomID uuid.UUID
+	HomeID uuid.UUID
+	Metric string `validate:"required,oneof=temperature humidity pressure co2 battery"`
+}
+
+type DeviceReadingDTO struct {
+	DeviceID   uuid.UUID `json:"device_id"`
+	Name       string    `json:"name"`
+	Value      float64   `json:"value"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// RoomReadingsDTO holds the latest reading of each device in a room that
+// reported recently, Average is null when none did.
+type RoomReadingsDTO struct {
+	RoomID  uuid.UUID          `json:"room_id"`
+	Name    string             `json:"name"`
+	Average *float64           `json:"average"`
+	Devices []DeviceReadingDTO `json:"devices"`
+}
+
+// CurrentReadingsDTO is the live view of a room or a home, Average is the
+// whole-house average when a home was asked for.
+type CurrentReadingsDTO struct {
+	HomeID  uuid.UUID         `json:"home_id"`
+	Metric  string            `json:"metric"`
+	Unit    string            `json:"unit"`
+	Average *float64          `json:"average"`
+	Rooms   []RoomReadingsDTO `json:"rooms"`
+}
@@ -1,13 +1,76 @@
 package contract
 
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
 type NewUserDTO struct {
 	FirstName string `json:"first_name" validate:"required,min=2,max=50"`
 	LastName  string `json:"last_name" validate:"required,min=2,max=50"`
 	Email     string `json:"email" validate:"required,email,max=60"`
 	Password  string `json:"password" validate:"required,min=6,max=30"`
+
+	TemperatureUnit string `json:"temperature_unit" validate:"omitempty,oneof=C F K"`
+}
+
+type UserPreferencesDTO struct {
+	TemperatureUnit string `json:"temperature_unit" validate:"required,oneof=C F K"`
+}
+
+// UpdateUserDTO is a partial update, nil fields are left unchanged.
+type UpdateUserDTO struct {
+	FirstName       *string `json:"first_name" validate:"omitnil,min=2,max=50"`
+	LastName        *string `json:"last_name" validate:"omitnil,min=2,max=50"`
+	TemperatureUnit *string `json:"temperature_unit" validate:"omitnil,oneof=C F K"`
+}
+
+// ChangePasswordDTO leaves CurrentPassword empty when setting the first
+// password of an account created through an identity provider, Code may
+// then carry an MFA code instead of signing in again.
+type ChangePasswordDTO struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" validate:"required,min=6,max=30"`
+	Code            string `json:"code"`
+}
+
+type DeleteUserDTO struct {
+	Password string `json:"password" validate:"required"`
 }
 
 type LoginDTO struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 }
+
+// UserResponseDTO is the only shape a user is returned to clients in, new
+// fields on domain.User stay private until they are mapped here.
+type UserResponseDTO struct {
+	ID              uuid.UUID `json:"id"`
+	FirstName       string    `json:"first_name"`
+	LastName        string    `json:"last_name"`
+	Email           string    `json:"email"`
+	TemperatureUnit string    `json:"temperature_unit"`
+	Role            string    `json:"role"`
+	EmailVerified   bool      `json:"email_verified"`
+	MFAEnabled      bool      `json:"mfa_enabled"`
+	HasPassword     bool      `json:"has_password"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+func NewUserResponseDTO(user *domain.User) UserResponseDTO {
+	return UserResponseDTO{
+		ID:              user.ID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Email:           user.Email,
+		TemperatureUnit: user.TemperatureUnit,
+		Role:            user.Role,
+		EmailVerified:   user.IsEmailVerified(),
+		MFAEnabled:      user.IsMFAEnabled(),
+		HasPassword:     user.HasPassword(),
+		CreatedAt:       user.CreatedAt,
+	}
+}
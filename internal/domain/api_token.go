@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APITokenPrefix starts every personal API token, so they can be told
+// apart from access tokens and found by secret scanners when leaked.
+const APITokenPrefix = "tsp_"
+
+// APIToken is a long-lived token a user creates for scripts and
+// integrations. It acts as the user but only with its scopes, and only
+// its hash is stored.
+type APIToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;index"`
+	Name      string
+	TokenHash string `gorm:"uniqueIndex"`
+	// Scopes are the permissions granted to the token, space separated.
+	Scopes     string
+	CreatedAt  time.Time
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+}
+
+func (t *APIToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+// IsActive reports whether the token is neither revoked nor expired at now.
+func (t *APIToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
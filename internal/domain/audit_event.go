@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AuditLoginAccountLocked = "login.account_locked"
+	AuditLoginIPLocked      = "login.ip_locked"
+	AuditMFALocked          = "mfa.locked"
+	AuditAdminRoleChanged   = "admin.role_changed"
+	AuditAdminSessionsEnded = "admin.sessions_revoked"
+	AuditAdminUserDeleted   = "admin.user_deleted"
+)
+
+// AuditEvent records a security relevant event. UserID is nil when the
+// event isn't tied to a known user, e.g. a lockout of an unknown email.
+// ActorID is the admin who acted on the user, if any.
+type AuditEvent struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primary_key"`
+	Type      string     `gorm:"index"`
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	ActorID   *uuid.UUID `gorm:"type:uuid"`
+	Email     string
+	IPAddress string
+	UserAgent string
+	Detail    string
+	CreatedAt time.Time
+}
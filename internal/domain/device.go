@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Device struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID `gorm:"type:uuid;index"`
+	Name       string
+	Room       string
+	Serial     string `gorm:"uniqueIndex:idx_devices_active_serial,where:retired_at IS NULL"`
+	SecretHash string
+	// RoomID assigns the device to a Room, Room is the free text label
+	// clients used before rooms existed.
+	RoomID *uuid.UUID `gorm:"type:uuid;index"`
+	// TemperatureUnit is the unit the device reports temperatures in when
+	// a reading doesn't say.
+	TemperatureUnit string `gorm:"not null;default:C"`
+	CreatedAt       time.Time
+	LastSeenAt      *time.Time
+	RetiredAt       *time.Time
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExternalIdentity links an account at an OpenID Connect provider, known
+// by its stable subject, to a user.
+type ExternalIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;index"`
+	Provider  string    `gorm:"uniqueIndex:idx_external_identity_subject"`
+	Subject   string    `gorm:"uniqueIndex:idx_external_identity_subject"`
+	Email     string
+	CreatedAt time.Time
+}
+
+// OIDCLoginState is an OpenID Connect login in progress, from the redirect
+// to the provider until the app brings back the authorization code.
+type OIDCLoginState struct {
+	ID           uuid.UUID `gorm:"type:uuid;primary_key"`
+	Provider     string
+	StateHash    string `gorm:"uniqueIndex"`
+	Nonce        string
+	CodeVerifier string
+	CreatedAt    time.Time
+	ExpiresAt    time.Time `gorm:"index"`
+}
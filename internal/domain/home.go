@@ -0,0 +1,36 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Home groups the rooms of one place, a user may have several.
+type Home struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;index"`
+	Name      string
+	CreatedAt time.Time
+}
+
+// Room is part of a home, devices are assigned to rooms so their readings
+// can be shown and averaged per room and per home. UserID is the owner of
+// its home, whoever created the room.
+type Room struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	HomeID    uuid.UUID `gorm:"type:uuid;index"`
+	UserID    uuid.UUID `gorm:"type:uuid;index"`
+	Name      string
+	CreatedAt time.Time
+}
+
+// HomeMember shares a home with a user other than its owner, their role
+// decides what they may do with its rooms and devices. The owner of a home
+// is always its admin and has no membership row.
+type HomeMember struct {
+	HomeID    uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;primary_key;index"`
+	Role      string
+	CreatedAt time.Time
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MetricTemperature = "temperature"
+	MetricHumidity    = "humidity"
+	MetricPressure    = "pressure"
+	MetricCO2         = "co2"
+	MetricBattery     = "battery"
+)
+
+// MetricSpec describes a metric the sensors may report, the unit readings
+// are stored in and the range of values that is physically plausible for a
+// home sensor.
+type MetricSpec struct {
+	Unit string
+	Min  float64
+	Max  float64
+}
+
+var Metrics = map[string]MetricSpec{
+	MetricTemperature: {Unit: "C", Min: -50, Max: 100},
+	MetricHumidity:    {Unit: "%", Min: 0, Max: 100},
+	MetricPressure:    {Unit: "hPa", Min: 300, Max: 1100},
+	MetricCO2:         {Unit: "ppm", Min: 0, Max: 10000},
+	MetricBattery:     {Unit: "V", Min: 0, Max: 5},
+}
+
+// Reading is a single metric measured by a device. Sensors reporting many
+// metrics at once produce one reading per metric, all with the same
+// RecordedAt.
+type Reading struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key"`
+	DeviceID   uuid.UUID `gorm:"type:uuid;index:idx_readings_device_metric_recorded_at,priority:1"`
+	UserID     uuid.UUID `gorm:"type:uuid;index"`
+	Metric     string    `gorm:"not null;default:temperature;index:idx_readings_device_metric_recorded_at,priority:2"`
+	Value      float64
+	Unit       string
+	RecordedAt time.Time `gorm:"index:idx_readings_device_metric_recorded_at,priority:3"`
+	ReceivedAt time.Time
+}
+
+type ReadingAggregate struct {
+	BucketStart time.Time
+	Min         float64
+	Max         float64
+	Avg         float64
+	Count       int64
+}
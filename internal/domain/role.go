@@ -0,0 +1,53 @@
+package domain
+
+// Roles a user can hold, carried in their access tokens, and the roles
+// they can be given in a home shared with them.
+const (
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	// RoleViewer sees devices and readings but can't change them.
+	RoleViewer = "viewer"
+)
+
+// Permissions are granted by roles and required by routes.
+const (
+	PermissionDevicesRead  = "devices:read"
+	PermissionDevicesWrite = "devices:write"
+	PermissionReadingsRead = "readings:read"
+	PermissionUsersManage  = "users:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin: {
+		PermissionDevicesRead,
+		PermissionDevicesWrite,
+		PermissionReadingsRead,
+		PermissionUsersManage,
+	},
+	RoleMember: {
+		PermissionDevicesRead,
+		PermissionDevicesWrite,
+		PermissionReadingsRead,
+	},
+	RoleViewer: {
+		PermissionDevicesRead,
+		PermissionReadingsRead,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RoleHasPermission reports whether role grants permission, unknown roles
+// grant nothing.
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
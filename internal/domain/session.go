@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Session is a login on one client. Its refresh tokens rotate within it and
+// its access tokens carry its ID, so revoking the session signs the client
+// out.
+type Session struct {
+	ID         uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID     uuid.UUID `gorm:"type:uuid;index"`
+	UserAgent  string
+	IPAddress  string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// RevokedToken is an access token ID or session ID rejected until
+// ExpiresAt, after which the tokens it covers are expired anyway.
+type RevokedToken struct {
+	ID        string    `gorm:"primary_key"`
+	ExpiresAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,41 @@
+package domain
+
+import "math"
+
+// Temperatures are stored in Celsius, devices may report and users may
+// prefer any of these units.
+const (
+	UnitCelsius    = "C"
+	UnitFahrenheit = "F"
+	UnitKelvin     = "K"
+)
+
+func IsTemperatureUnit(unit string) bool {
+	return unit == UnitCelsius || unit == UnitFahrenheit || unit == UnitKelvin
+}
+
+// ToCelsius converts a temperature reported in unit to Celsius. Unknown
+// units are assumed to be Celsius already.
+func ToCelsius(value float64, unit string) float64 {
+	switch unit {
+	case UnitFahrenheit:
+		return (value - 32) * 5 / 9
+	case UnitKelvin:
+		return value - 273.15
+	}
+
+	return value
+}
+
+// FromCelsius converts a Celsius temperature to unit for display, rounded
+// to two decimals to hide float noise from the conversion.
+func FromCelsius(value float64, unit string) float64 {
+	switch unit {
+	case UnitFahrenheit:
+		value = value*9/5 + 32
+	case UnitKelvin:
+		value = value + 273.15
+	}
+
+	return math.Round(value*100) / 100
+}
@@ -12,5 +12,29 @@ type User struct {
 	LastName  string
 	Email     string
 	Password  string
-	CreatedAt time.Time
+	// TemperatureUnit is the unit temperatures are shown to the user in.
+	TemperatureUnit string `gorm:"not null;default:C"`
+	Role            string `gorm:"not null;default:member"`
+	EmailVerifiedAt *time.Time
+	// TOTPSecret is set when TOTP enrollment starts, TOTPEnabledAt once the
+	// user confirmed it with a first code. TOTPLastStep is the time step of
+	// the last accepted code, so a code can't be replayed.
+	TOTPSecret    string
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64
+	CreatedAt     time.Time
+}
+
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// HasPassword is false for accounts created through an identity provider
+// until the user sets a password.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
+func (u *User) IsMFAEnabled() bool {
+	return u.TOTPEnabledAt != nil
 }
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AdminHandler serves the user management routes, they are guarded by
+// RequirePermission(domain.PermissionUsersManage).
+type AdminHandler struct {
+	adminService service.AdminService
+}
+
+func NewAdminHandler(service service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: service}
+}
+
+func (h *AdminHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	query := contract.ListUsersQueryDTO{Email: params.Get("email"), Page: 1, Limit: 20}
+
+	if page := params.Get("page"); page != "" {
+		number, err := strconv.Atoi(page)
+		if err != nil {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+		query.Page = number
+	}
+
+	if limit := params.Get("limit"); limit != "" {
+		number, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		query.Limit = number
+	}
+
+	users, err := h.adminService.ListUsers(&query)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(users)
+}
+
+func (h *AdminHandler) FindUser(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.FindUser(id)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewUserResponseDTO(user))
+}
+
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.SetRoleDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.adminService.SetRole(principal.UserID, id, &dto)
+	if err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewUserResponseDTO(user))
+}
+
+func (h *AdminHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.adminService.RevokeSessions(principal.UserID, id); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AdminHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.adminService.DeleteUser(principal.UserID, id); err != nil {
+		writeAdminError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAdminError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrActOnSelf):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		writeUserError(w, err)
+	}
+}
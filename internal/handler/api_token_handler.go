@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type APITokenHandler struct {
+	apiTokenService service.APITokenService
+}
+
+func NewAPITokenHandler(service service.APITokenService) *APITokenHandler {
+	return &APITokenHandler{apiTokenService: service}
+}
+
+func (h *APITokenHandler) CreateToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dto contract.NewAPITokenDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	apiToken, secret, err := h.apiTokenService.CreateToken(userID, &dto)
+	if err != nil {
+		writeAPITokenError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(contract.APITokenCreatedResponseDTO{
+		APITokenResponseDTO: contract.NewAPITokenResponseDTO(apiToken),
+		Token:               secret,
+	})
+}
+
+func (h *APITokenHandler) ListTokens(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	apiTokens, err := h.apiTokenService.ListTokens(userID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	response := make([]contract.APITokenResponseDTO, 0, len(apiTokens))
+	for i := range apiTokens {
+		response = append(response, contract.NewAPITokenResponseDTO(&apiTokens[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *APITokenHandler) RevokeToken(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.apiTokenService.RevokeToken(userID, id); err != nil {
+		writeAPITokenError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAPITokenError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAPITokenNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrScopeNotGranted):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		writeUserError(w, err)
+	}
+}
@@ -2,31 +2,326 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 
 	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
 	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/azevedoguigo/thermosync-api/pkg"
 )
 
 type AuthHandler struct {
-	userService service.UserService
+	authService service.AuthService
 }
 
-func NewAuthHandler(service service.UserService) *AuthHandler {
-	return &AuthHandler{userService: service}
+func NewAuthHandler(service service.AuthService) *AuthHandler {
+	return &AuthHandler{authService: service}
 }
 
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var credentials contract.LoginDTO
 	json.NewDecoder(r.Body).Decode(&credentials)
 
-	token, err := h.userService.Login(credentials.Email, credentials.Password)
+	tokens, challenge, err := h.authService.Login(credentials.Email, credentials.Password, clientInfo(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusUnauthorized)
+		writeAuthError(w, err)
+		return
+	}
+
+	if challenge != nil {
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// VerifyMFA is the second step of a login with two-factor authentication.
+func (h *AuthHandler) VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	var dto contract.VerifyMFADTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.VerifyMFA(dto.MFAToken, dto.Code, clientInfo(r))
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var dto contract.RefreshTokenDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	tokens, err := h.authService.Refresh(dto.RefreshToken)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.authService.Logout(principal.TokenID, principal.SessionID, principal.ExpiresAt); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The current access token may predate its session, revoke it too.
+	if err := h.authService.Logout(principal.TokenID, principal.SessionID, principal.ExpiresAt); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if err := h.authService.LogoutAll(principal.UserID); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessions, err := h.authService.ListSessions(principal.UserID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	response := make([]contract.SessionResponseDTO, 0, len(sessions))
+	for i := range sessions {
+		response = append(response, contract.NewSessionResponseDTO(&sessions[i], principal.SessionID))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// clientInfo describes the client of r, RealIP has already replaced
+// RemoteAddr with the forwarded address when behind a trusted proxy.
+func clientInfo(r *http.Request) service.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return service.ClientInfo{UserAgent: r.UserAgent(), IPAddress: ip}
+}
+
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var dto contract.VerifyEmailDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.VerifyEmail(dto.Token); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification always answers 202 so it can't be used to probe
+// which emails are registered.
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	var dto contract.ResendVerificationDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.ResendVerificationEmail(dto.Email); err != nil {
+		log.Printf("Failed to resend verification email: %s", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword always answers 202 so it can't be used to probe which
+// emails are registered.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var dto contract.ForgotPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.ForgotPassword(dto.Email); err != nil {
+		log.Printf("Failed to send password reset email: %s", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var dto contract.ResetPasswordDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.ResetPassword(&dto); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.authService.EnrollTOTP(principal.UserID)
+	if err != nil {
+		writeAuthError(w, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-	})
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dto contract.ConfirmTOTPDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recoveryCodes, err := h.authService.ConfirmTOTP(principal.UserID, dto.Code)
+	if err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(recoveryCodes)
+}
+
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dto contract.DisableTOTPDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.authService.DisableTOTP(principal.UserID, &dto); err != nil {
+		writeAuthError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeAuthError(w http.ResponseWriter, err error) {
+	var locked *service.LoginLockedError
+	if errors.As(err, &locked) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidVerificationToken), errors.Is(err, service.ErrInvalidResetToken):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrEmailNotVerified),
+		errors.Is(err, service.ErrInvalidMFACode),
+		errors.Is(err, service.ErrIncorrectPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidRefreshToken),
+		errors.Is(err, service.ErrRefreshTokenReused),
+		errors.Is(err, service.ErrInvalidMFAToken):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrMFAAlreadyEnabled), errors.Is(err, service.ErrMFANotEnabled):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeRequestError(w, err)
+	}
 }
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type DeviceHandler struct {
+	deviceService service.DeviceService
+}
+
+func NewDeviceHandler(service service.DeviceService) *DeviceHandler {
+	return &DeviceHandler{deviceService: service}
+}
+
+func (h *DeviceHandler) CreateDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dto contract.NewDeviceDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	device, secret, err := h.deviceService.CreateDevice(userID, &dto)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(contract.DeviceCredentialsResponseDTO{
+		DeviceResponseDTO: contract.NewDeviceResponseDTO(device),
+		Secret:            secret,
+	})
+}
+
+func (h *DeviceHandler) ListDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	devices, err := h.deviceService.ListDevices(userID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	response := make([]contract.DeviceResponseDTO, 0, len(devices))
+	for i := range devices {
+		response = append(response, contract.NewDeviceResponseDTO(&devices[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *DeviceHandler) FindDeviceByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.deviceService.FindDevice(userID, deviceID)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewDeviceResponseDTO(device))
+}
+
+func (h *DeviceHandler) UpdateDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.UpdateDeviceDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.deviceService.UpdateDevice(userID, deviceID, &dto)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewDeviceResponseDTO(device))
+}
+
+func (h *DeviceHandler) RetireDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.deviceService.RetireDevice(userID, deviceID); err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DeviceHandler) RotateDeviceSecret(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, secret, err := h.deviceService.RotateDeviceSecret(userID, deviceID)
+	if err != nil {
+		writeDeviceError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.DeviceCredentialsResponseDTO{
+		DeviceResponseDTO: contract.NewDeviceResponseDTO(device),
+		Secret:            secret,
+	})
+}
+
+func writeDeviceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrDeviceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrDeviceRetired):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrHomeAccessDenied):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		writeRequestError(w, err)
+	}
+}
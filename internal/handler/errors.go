@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/pkg"
+)
+
+// writeRequestError answers errors the write*Error helpers don't know.
+// Invalid input is reported with its message, anything else is an
+// internal error.
+func writeRequestError(w http.ResponseWriter, err error) {
+	var input *pkg.InputError
+	if errors.As(err, &input) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeInternalError(w, err)
+}
+
+// writeInternalError logs err and answers with a generic message, so
+// database and driver details never reach the client.
+func writeInternalError(w http.ResponseWriter, err error) {
+	log.Println("Internal server error:", err.Error())
+	http.Error(w, "internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,439 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type HomeHandler struct {
+	homeService service.HomeService
+}
+
+func NewHomeHandler(service service.HomeService) *HomeHandler {
+	return &HomeHandler{homeService: service}
+}
+
+func (h *HomeHandler) CreateHome(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var dto contract.NewHomeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	home, err := h.homeService.CreateHome(userID, &dto)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(contract.NewHomeResponseDTO(home))
+}
+
+func (h *HomeHandler) ListHomes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homes, err := h.homeService.ListHomes(userID)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	response := make([]contract.HomeResponseDTO, 0, len(homes))
+	for i := range homes {
+		response = append(response, contract.NewHomeResponseDTO(&homes[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HomeHandler) FindHomeByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	home, err := h.homeService.FindHome(userID, homeID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewHomeResponseDTO(home))
+}
+
+func (h *HomeHandler) UpdateHome(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.UpdateHomeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	home, err := h.homeService.UpdateHome(userID, homeID, &dto)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewHomeResponseDTO(home))
+}
+
+func (h *HomeHandler) DeleteHome(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.homeService.DeleteHome(userID, homeID); err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HomeHandler) CreateRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.NewRoomDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.homeService.CreateRoom(userID, homeID, &dto)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(contract.NewRoomResponseDTO(room))
+}
+
+func (h *HomeHandler) ListRooms(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rooms, err := h.homeService.ListRooms(userID, homeID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	response := make([]contract.RoomResponseDTO, 0, len(rooms))
+	for i := range rooms {
+		response = append(response, contract.NewRoomResponseDTO(&rooms[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HomeHandler) FindRoomByID(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.homeService.FindRoom(userID, roomID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewRoomResponseDTO(room))
+}
+
+func (h *HomeHandler) UpdateRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.UpdateRoomDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	room, err := h.homeService.UpdateRoom(userID, roomID, &dto)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewRoomResponseDTO(room))
+}
+
+func (h *HomeHandler) DeleteRoom(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.homeService.DeleteRoom(userID, roomID); err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HomeHandler) ListRoomDevices(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	devices, err := h.homeService.ListRoomDevices(userID, roomID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	response := make([]contract.DeviceResponseDTO, 0, len(devices))
+	for i := range devices {
+		response = append(response, contract.NewDeviceResponseDTO(&devices[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HomeHandler) AssignDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, deviceID, err := roomDeviceParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.homeService.AssignDevice(userID, roomID, deviceID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewDeviceResponseDTO(device))
+}
+
+func (h *HomeHandler) UnassignDevice(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	roomID, deviceID, err := roomDeviceParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.homeService.UnassignDevice(userID, roomID, deviceID); err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *HomeHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	members, err := h.homeService.ListMembers(userID, homeID)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	response := make([]contract.HomeMemberResponseDTO, 0, len(members))
+	for i := range members {
+		response = append(response, contract.NewHomeMemberResponseDTO(&members[i]))
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *HomeHandler) SetMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto contract.SetHomeMemberDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	member, err := h.homeService.SetMember(userID, homeID, &dto)
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(contract.NewHomeMemberResponseDTO(member))
+}
+
+func (h *HomeHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	homeID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := uuid.Parse(chi.URLParam(r, "userID"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.homeService.RemoveMember(userID, homeID, memberID); err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func roomDeviceParams(r *http.Request) (uuid.UUID, uuid.UUID, error) {
+	roomID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	deviceID, err := uuid.Parse(chi.URLParam(r, "deviceID"))
+	if err != nil {
+		return uuid.Nil, uuid.Nil, err
+	}
+
+	return roomID, deviceID, nil
+}
+
+func writeHomeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrHomeNotFound), errors.Is(err, service.ErrRoomNotFound),
+		errors.Is(err, service.ErrHomeMemberNotFound), errors.Is(err, service.ErrUserNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		writeDeviceError(w, err)
+	}
+}
@@ -0,0 +1,24 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+)
+
+type JWKSHandler struct {
+	tokens token.Service
+}
+
+func NewJWKSHandler(tokens token.Service) *JWKSHandler {
+	return &JWKSHandler{tokens: tokens}
+}
+
+// GetJWKS publishes the public signing keys, current and retired, so other
+// services can verify access tokens without sharing a secret.
+func (h *JWKSHandler) GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.tokens.JWKS())
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/go-chi/chi/v5"
+)
+
+type OIDCHandler struct {
+	oidcService service.OIDCService
+}
+
+func NewOIDCHandler(service service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{oidcService: service}
+}
+
+// StartLogin returns the provider URL the app opens for the user to sign
+// in, the provider redirects back to the app with a code and the state.
+func (h *OIDCHandler) StartLogin(w http.ResponseWriter, r *http.Request) {
+	authorization, err := h.oidcService.StartLogin(chi.URLParam(r, "provider"))
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(authorization)
+}
+
+// CompleteLogin answers like a password login: tokens, or an MFA challenge
+// for users with two-factor authentication.
+func (h *OIDCHandler) CompleteLogin(w http.ResponseWriter, r *http.Request) {
+	var dto contract.OIDCCallbackDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		http.Error(w, "Invalid request payload!", http.StatusBadRequest)
+		return
+	}
+
+	if err := pkg.ValidateStruct(&dto); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tokens, challenge, err := h.oidcService.CompleteLogin(chi.URLParam(r, "provider"), &dto, clientInfo(r))
+	if err != nil {
+		writeOIDCError(w, err)
+		return
+	}
+
+	if challenge != nil {
+		json.NewEncoder(w).Encode(challenge)
+		return
+	}
+
+	json.NewEncoder(w).Encode(tokens)
+}
+
+func writeOIDCError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUnknownOIDCProvider):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidOIDCState), errors.Is(err, service.ErrOIDCLoginFailed):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrOIDCEmailNotVerified):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrOIDCAccountNotVerified):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		writeAuthError(w, err)
+	}
+}
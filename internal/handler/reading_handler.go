@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/google/uuid"
+)
+
+type ReadingHandler struct {
+	readingService service.ReadingService
+}
+
+func NewReadingHandler(service service.ReadingService) *ReadingHandler {
+	return &ReadingHandler{readingService: service}
+}
+
+func (h *ReadingHandler) GetReadingHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+
+	deviceID, err := uuidParam(params, "device_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	roomID, err := uuidParam(params, "room_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	homeID, err := uuidParam(params, "home_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	from, err := time.Parse(time.RFC3339, params.Get("from"))
+	if err != nil {
+		http.Error(w, "Invalid from, expected RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	to, err := time.Parse(time.RFC3339, params.Get("to"))
+	if err != nil {
+		http.Error(w, "Invalid to, expected RFC3339 timestamp", http.StatusBadRequest)
+		return
+	}
+
+	metric := params.Get("metric")
+	if metric == "" {
+		metric = domain.MetricTemperature
+	}
+
+	history, err := h.readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		DeviceID: deviceID,
+		RoomID:   roomID,
+		HomeID:   homeID,
+		Metric:   metric,
+		From:     from,
+		To:       to,
+		Bucket:   params.Get("bucket"),
+	})
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(history)
+}
+
+// GetCurrentReadings returns the latest readings of the devices in the
+// room or home given by the room_id or home_id query parameter.
+func (h *ReadingHandler) GetCurrentReadings(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.UserIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+
+	roomID, err := uuidParam(params, "room_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	homeID, err := uuidParam(params, "home_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric := params.Get("metric")
+	if metric == "" {
+		metric = domain.MetricTemperature
+	}
+
+	current, err := h.readingService.GetCurrentReadings(userID, &contract.CurrentReadingsQueryDTO{
+		RoomID: roomID,
+		HomeID: homeID,
+		Metric: metric,
+	})
+	if err != nil {
+		writeHomeError(w, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(current)
+}
+
+// uuidParam parses the optional query parameter name, uuid.Nil when it is
+// missing.
+func uuidParam(params url.Values, name string) (uuid.UUID, error) {
+	value := params.Get(name)
+	if value == "" {
+		return uuid.Nil, nil
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, errors.New("Invalid " + name)
+	}
+
+	return id, nil
+}
@@ -2,13 +2,19 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/azevedoguigo/thermosync-api/internal/contract"
 	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -26,14 +32,38 @@ func (m *mockUserService) CreateUser(userDTO *contract.NewUserDTO) error {
 func (m *mockUserService) FindUserByID(id uuid.UUID) (*domain.User, error) {
 	args := m.Called(id)
 	if user := args.Get(0); user != nil {
-		return user.(*domain.User), args.Error(0)
+		return user.(*domain.User), args.Error(1)
 	}
 
 	return nil, args.Error(1)
 }
 
-func (m *mockUserService) Login(email string, password string) (string, error) {
-	panic("unimplemented")
+func (m *mockUserService) UpdatePreferences(id uuid.UUID, preferencesDTO *contract.UserPreferencesDTO) (*domain.User, error) {
+	args := m.Called(id, preferencesDTO)
+	if user := args.Get(0); user != nil {
+		return user.(*domain.User), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *mockUserService) UpdateUser(id uuid.UUID, userDTO *contract.UpdateUserDTO) (*domain.User, error) {
+	args := m.Called(id, userDTO)
+	if user := args.Get(0); user != nil {
+		return user.(*domain.User), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *mockUserService) ChangePassword(id, sessionID uuid.UUID, passwordDTO *contract.ChangePasswordDTO) error {
+	args := m.Called(id, sessionID, passwordDTO)
+	return args.Error(0)
+}
+
+func (m *mockUserService) DeleteUser(id uuid.UUID, deleteDTO *contract.DeleteUserDTO) error {
+	args := m.Called(id, deleteDTO)
+	return args.Error(0)
 }
 
 func TestUserHandler_Create_Success(t *testing.T) {
@@ -61,3 +91,111 @@ func TestUserHandler_Create_Success(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, recorderResponse.Code)
 }
+
+func newUserRequest(principal *middleware.Principal, id string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/users/"+id, nil)
+
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add("id", id)
+
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, routeContext)
+	ctx = middleware.WithPrincipal(ctx, principal)
+
+	return req.WithContext(ctx)
+}
+
+func TestUserHandler_FindUserByID_Own(t *testing.T) {
+	userID := uuid.New()
+
+	mockService := new(mockUserService)
+	mockService.On("FindUserByID", userID).Return(&domain.User{ID: userID}, nil)
+
+	handler := NewUserHandler(mockService)
+
+	recorderResponse := httptest.NewRecorder()
+	handler.FindUserByID(recorderResponse, newUserRequest(&middleware.Principal{UserID: userID, Roles: []string{"member"}}, userID.String()))
+
+	assert.Equal(t, http.StatusOK, recorderResponse.Code)
+}
+
+func TestUserHandler_FindUserByID_OtherUserIsForbidden(t *testing.T) {
+	mockService := new(mockUserService)
+
+	handler := NewUserHandler(mockService)
+
+	recorderResponse := httptest.NewRecorder()
+	handler.FindUserByID(recorderResponse, newUserRequest(&middleware.Principal{UserID: uuid.New(), Roles: []string{"member"}}, uuid.NewString()))
+
+	assert.Equal(t, http.StatusForbidden, recorderResponse.Code)
+	mockService.AssertNumberOfCalls(t, "FindUserByID", 0)
+}
+
+func TestUserHandler_FindUserByID_AdminOverride(t *testing.T) {
+	otherID := uuid.New()
+
+	mockService := new(mockUserService)
+	mockService.On("FindUserByID", otherID).Return(&domain.User{ID: otherID}, nil)
+
+	handler := NewUserHandler(mockService)
+
+	recorderResponse := httptest.NewRecorder()
+	handler.FindUserByID(recorderResponse, newUserRequest(&middleware.Principal{UserID: uuid.New(), Roles: []string{"admin"}}, otherID.String()))
+
+	assert.Equal(t, http.StatusOK, recorderResponse.Code)
+}
+
+func TestUserHandler_GetMe(t *testing.T) {
+	userID := uuid.New()
+
+	mockService := new(mockUserService)
+	mockService.On("FindUserByID", userID).Return(&domain.User{ID: userID, Email: "senna@example.com", Password: "$2a$10$hash"}, nil)
+
+	handler := NewUserHandler(mockService)
+
+	recorderResponse := httptest.NewRecorder()
+	handler.GetMe(recorderResponse, newUserRequest(&middleware.Principal{UserID: userID}, "me"))
+
+	assert.Equal(t, http.StatusOK, recorderResponse.Code)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(recorderResponse.Body.Bytes(), &body))
+	assert.Equal(t, "senna@example.com", body["email"])
+	assert.Equal(t, userID.String(), body["id"])
+	assert.NotContains(t, body, "password")
+	assert.NotContains(t, body, "Password")
+	assert.NotContains(t, recorderResponse.Body.String(), "$2a$10$hash")
+}
+
+func TestUserHandler_DeleteMe(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		body string
+	}{
+		{"deleted", nil, http.StatusNoContent, ""},
+		{"wrong password", service.ErrIncorrectPassword, http.StatusForbidden, "current password is incorrect\n"},
+		{"invalid input", pkg.NewInputError("Password is required"), http.StatusBadRequest, "Password is required\n"},
+		{"database error", errors.New(`pq: relation "users" does not exist`), http.StatusInternalServerError, "internal server error\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+
+			mockService := new(mockUserService)
+			mockService.On("DeleteUser", userID, &contract.DeleteUserDTO{Password: "supersenha"}).Return(tt.err)
+
+			handler := NewUserHandler(mockService)
+
+			req := httptest.NewRequest(http.MethodDelete, "/users/me", bytes.NewBufferString(`{"password":"supersenha"}`))
+			req = req.WithContext(middleware.WithPrincipal(req.Context(), &middleware.Principal{UserID: userID}))
+
+			recorderResponse := httptest.NewRecorder()
+			handler.DeleteMe(recorderResponse, req)
+
+			assert.Equal(t, tt.code, recorderResponse.Code)
+			assert.Equal(t, tt.body, recorderResponse.Body.String())
+		})
+	}
+}
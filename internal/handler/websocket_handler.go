@@ -1,11 +1,174 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/middleware"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
 	"github.com/azevedoguigo/thermosync-api/internal/websocket"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
 )
 
-func Websocket(w http.ResponseWriter, r *http.Request) {
-	websocket.HanldeConnections(w, r)
+type WebsocketHandler struct {
+	hub            *websocket.Hub
+	userService    service.UserService
+	deviceService  service.DeviceService
+	homeService    service.HomeService
+	readingService service.ReadingService
+}
+
+func NewWebsocketHandler(hub *websocket.Hub, userService service.UserService, deviceService service.DeviceService, homeService service.HomeService, readingService service.ReadingService) *WebsocketHandler {
+	return &WebsocketHandler{hub: hub, userService: userService, deviceService: deviceService, homeService: homeService, readingService: readingService}
+}
+
+// Websocket subscribes the authenticated user to the readings of the devices
+// they own, or only to the one given by the device_id query parameter. The
+// client may later subscribe to, or send commands to, any device it owns.
+// Temperatures are pushed in the user's preferred unit.
+//
+// With room_id or home_id instead, the client is subscribed to the devices
+// in that room or home and also gets the room and whole-house averages of
+// the metric query parameter, temperature by default, as current_readings
+// frames on connect and after every reading.
+func (h *WebsocketHandler) Websocket(w http.ResponseWriter, r *http.Request) {
+	principal, ok := middleware.PrincipalFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	userID := principal.UserID
+
+	user, err := h.userService.FindUserByID(userID)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusUnauthorized)
+		return
+	}
+
+	params := r.URL.Query()
+
+	roomID, err := uuidParam(params, "room_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	homeID, err := uuidParam(params, "home_id")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var deviceIDs []uuid.UUID
+	var current func() (*contract.CurrentReadingsDTO, error)
+
+	if roomID != uuid.Nil || homeID != uuid.Nil {
+		if (roomID != uuid.Nil && homeID != uuid.Nil) || params.Get("device_id") != "" {
+			http.Error(w, "Only one of device_id, room_id or home_id may be given", http.StatusBadRequest)
+			return
+		}
+
+		query := &contract.CurrentReadingsQueryDTO{RoomID: roomID, HomeID: homeID, Metric: params.Get("metric")}
+		if query.Metric == "" {
+			query.Metric = domain.MetricTemperature
+		}
+		if err := pkg.ValidateStruct(query); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var devices []domain.Device
+		if roomID != uuid.Nil {
+			devices, err = h.homeService.ListRoomDevices(userID, roomID)
+		} else {
+			devices, err = h.homeService.ListHomeDevices(userID, homeID)
+		}
+		if err != nil {
+			writeHomeError(w, err)
+			return
+		}
+
+		for _, device := range devices {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+
+		current = func() (*contract.CurrentReadingsDTO, error) {
+			return h.readingService.GetCurrentReadings(userID, query)
+		}
+	} else if param := params.Get("device_id"); param != "" {
+		deviceID, err := uuid.Parse(param)
+		if err != nil {
+			http.Error(w, "Invalid device_id", http.StatusBadRequest)
+			return
+		}
+
+		device, err := h.deviceService.FindDevice(userID, deviceID)
+		if err != nil {
+			writeDeviceError(w, err)
+			return
+		}
+
+		deviceIDs = append(deviceIDs, device.ID)
+	} else {
+		devices, err := h.deviceService.ListDevices(userID)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+
+		for _, device := range devices {
+			deviceIDs = append(deviceIDs, device.ID)
+		}
+	}
+
+	authorize := func(deviceID uuid.UUID) error {
+		_, err := h.deviceService.FindDevice(userID, deviceID)
+		return err
+	}
+
+	h.hub.ServeClient(w, r, websocket.ClientOptions{
+		UserID:          userID,
+		DeviceIDs:       deviceIDs,
+		TemperatureUnit: user.TemperatureUnit,
+		Authorize:       authorize,
+		Current:         current,
+		AuthorizeCommand: func(deviceID uuid.UUID) error {
+			if !principal.Can(domain.PermissionDevicesWrite) {
+				return errors.New("your role can't send commands to devices")
+			}
+			return authorize(deviceID)
+		},
+	})
+}
+
+// DeviceWebsocket authenticates the device on the handshake with HTTP basic
+// auth, using the device ID as username and the device secret as password.
+func (h *WebsocketHandler) DeviceWebsocket(w http.ResponseWriter, r *http.Request) {
+	username, secret, ok := r.BasicAuth()
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="devices"`)
+		http.Error(w, "Device credentials required", http.StatusUnauthorized)
+		return
+	}
+
+	deviceID, err := uuid.Parse(username)
+	if err != nil {
+		http.Error(w, service.ErrInvalidDeviceCredentials.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	device, err := h.deviceService.AuthenticateDevice(deviceID, secret)
+	if errors.Is(err, service.ErrInvalidDeviceCredentials) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+
+	h.hub.ServeDevice(w, r, device, h.readingService)
 }
@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailer_WritesEML(t *testing.T) {
+	dir := t.TempDir()
+
+	mailer, err := NewFileMailer(dir, "ThermoSync <no-reply@thermosync.dev>")
+	require.NoError(t, err)
+
+	err = mailer.Send(Message{To: "senna@example.com", Subject: "Verify your email", Body: "line one\nline two"})
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: senna@example.com\r\n")
+	assert.Contains(t, string(content), "Subject: Verify your email\r\n")
+	assert.Contains(t, string(content), "\r\n\r\nline one\r\nline two")
+}
+
+func TestFormat_StripsHeaderInjection(t *testing.T) {
+	content := string(format("no-reply@thermosync.dev", Message{
+		To:      "senna@example.com\r\nBcc: attacker@example.com",
+		Subject: "Hi",
+	}))
+
+	assert.NotContains(t, content, "\r\nBcc:")
+}
+
+func TestMemoryMailer_KeepsMessages(t *testing.T) {
+	mailer := NewMemoryMailer()
+
+	require.NoError(t, mailer.Send(Message{To: "a@example.com"}))
+	require.NoError(t, mailer.Send(Message{To: "b@example.com"}))
+
+	messages := mailer.Messages()
+	require.Len(t, messages, 2)
+	assert.Equal(t, "a@example.com", messages[0].To)
+	assert.Equal(t, "b@example.com", messages[1].To)
+}
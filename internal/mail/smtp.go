@@ -0,0 +1,61 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type smtpMailer struct {
+	config SMTPConfig
+	auth   smtp.Auth
+}
+
+// NewSMTPMailer sends mail through an SMTP relay, authenticating with
+// PLAIN auth when a username is configured. net/smtp upgrades to TLS when
+// the server offers STARTTLS and refuses PLAIN auth without it.
+func NewSMTPMailer(config SMTPConfig) Mailer {
+	mailer := &smtpMailer{config: config}
+	if config.Username != "" {
+		mailer.auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	return mailer
+}
+
+func (m *smtpMailer) Send(message Message) error {
+	addr := net.JoinHostPort(m.config.Host, m.config.Port)
+
+	return smtp.SendMail(addr, m.auth, m.config.From, []string{message.To}, format(m.config.From, message))
+}
+
+// format renders message as an RFC 5322 email, header values are stripped
+// of line breaks so they can't inject headers.
+func format(from string, message Message) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(message.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(message.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return []byte(b.String())
+}
+
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
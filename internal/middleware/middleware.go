@@ -1,35 +1,114 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
-	"github.com/go-chi/jwtauth/v5"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/google/uuid"
 )
 
-var tokenAuth = jwtauth.New("HS256", []byte("secretkey"), nil)
-
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		tokenString := r.Header.Get("Authorization")
-		if tokenString == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
-			return
-		}
-
-		if strings.HasPrefix(tokenString, "Bearer ") {
-			tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		} else {
-			http.Error(w, "Authentication header must be of type Bearer", http.StatusUnauthorized)
-			return
-		}
-
-		_, err := tokenAuth.Decode(tokenString)
-		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
+type contextKey string
+
+const principalKey contextKey = "principal"
+
+// APITokenAuthenticator resolves personal API tokens, APITokenService
+// implements it.
+type APITokenAuthenticator interface {
+	// AuthenticateAPIToken returns the token and the roles of its owner, or
+	// token.ErrInvalidToken.
+	AuthenticateAPIToken(secret string) (*domain.APIToken, []string, error)
+}
+
+// AuthMiddleware rejects requests without a valid Bearer token issued by
+// tokens, or whose token or session was revoked, and stores the caller's
+// Principal in the request context. Personal API tokens are accepted too
+// when apiTokens is not nil, routes that manage the account itself leave
+// it nil so a leaked API token can't take the account over.
+func AuthMiddleware(tokens token.Service, revocations token.RevocationStore, apiTokens APITokenAuthenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString := r.Header.Get("Authorization")
+			if tokenString == "" {
+				http.Error(w, "Authorization header required", http.StatusUnauthorized)
+				return
+			}
+
+			if strings.HasPrefix(tokenString, "Bearer ") {
+				tokenString = strings.TrimPrefix(tokenString, "Bearer ")
+			} else {
+				http.Error(w, "Authentication header must be of type Bearer", http.StatusUnauthorized)
+				return
+			}
+
+			if strings.HasPrefix(tokenString, domain.APITokenPrefix) {
+				authenticateAPIToken(apiTokens, tokenString, next, w, r)
+				return
+			}
+
+			claims, err := tokens.Verify(tokenString)
+			if err != nil {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+
+			revoked, err := isRevoked(revocations, claims)
+			if err != nil {
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
+			}
+			if revoked {
+				http.Error(w, "Token revoked", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := WithPrincipal(r.Context(), &Principal{
+				UserID:    claims.UserID,
+				Roles:     claims.Roles,
+				TokenID:   claims.ID,
+				SessionID: claims.SessionID,
+				ExpiresAt: claims.ExpiresAt,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func authenticateAPIToken(apiTokens APITokenAuthenticator, secret string, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if apiTokens == nil {
+		http.Error(w, "API tokens are not accepted here", http.StatusUnauthorized)
+		return
+	}
+
+	apiToken, roles, err := apiTokens.AuthenticateAPIToken(secret)
+	if errors.Is(err, token.ErrInvalidToken) {
+		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	principal := &Principal{
+		UserID:     apiToken.UserID,
+		Roles:      roles,
+		APITokenID: apiToken.ID,
+		Scopes:     apiToken.ScopeList(),
+	}
+	if apiToken.ExpiresAt != nil {
+		principal.ExpiresAt = *apiToken.ExpiresAt
+	}
+
+	next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+}
+
+func isRevoked(revocations token.RevocationStore, claims *token.Claims) (bool, error) {
+	if claims.SessionID == uuid.Nil {
+		return revocations.IsRevoked(claims.ID)
+	}
+
+	return revocations.IsRevoked(claims.ID, claims.SessionID.String())
 }
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTokens(t *testing.T) token.Service {
+	cfg := token.DefaultConfig()
+	cfg.Keys = []token.Key{{ID: "test", Secret: []byte(strings.Repeat("s", token.MinSecretLength))}}
+
+	tokens, err := token.NewService(cfg)
+	require.NoError(t, err)
+
+	return tokens
+}
+
+type memoryRevocations map[string]time.Time
+
+func (m memoryRevocations) Revoke(id string, expiresAt time.Time) error {
+	m[id] = expiresAt
+	return nil
+}
+
+func (m memoryRevocations) IsRevoked(ids ...string) (bool, error) {
+	for _, id := range ids {
+		if _, ok := m[id]; ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// memoryAPITokens maps API token secrets to their tokens, all owned by
+// members.
+type memoryAPITokens map[string]*domain.APIToken
+
+func (m memoryAPITokens) AuthenticateAPIToken(secret string) (*domain.APIToken, []string, error) {
+	apiToken, ok := m[secret]
+	if !ok {
+		return nil, nil, token.ErrInvalidToken
+	}
+
+	return apiToken, []string{domain.RoleMember}, nil
+}
+
+func serve(tokens token.Service, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	return serveWithRevocations(tokens, memoryRevocations{}, authorization)
+}
+
+func serveWithRevocations(tokens token.Service, revocations token.RevocationStore, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	return serveWith(tokens, revocations, nil, authorization)
+}
+
+func serveWith(tokens token.Service, revocations token.RevocationStore, apiTokens APITokenAuthenticator, authorization string) (*httptest.ResponseRecorder, *Principal) {
+	var principal *Principal
+
+	handler := AuthMiddleware(tokens, revocations, apiTokens)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ = PrincipalFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	return rr, principal
+}
+
+func TestAuthMiddleware_AcceptsIssuedToken(t *testing.T) {
+	tokens := newTestTokens(t)
+	userID := uuid.New()
+
+	sessionID := uuid.New()
+
+	tokenString, claims, err := tokens.Issue(token.Claims{UserID: userID, SessionID: sessionID, Roles: []string{"member"}})
+	require.NoError(t, err)
+
+	rr, principal := serve(tokens, "Bearer "+tokenString)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, userID, principal.UserID)
+	assert.Equal(t, sessionID, principal.SessionID)
+	assert.Equal(t, claims.ID, principal.TokenID)
+	assert.Equal(t, []string{"member"}, principal.Roles)
+}
+
+func TestAuthMiddleware_RejectsRequests(t *testing.T) {
+	tokens := newTestTokens(t)
+
+	tests := []struct {
+		name          string
+		authorization string
+		body          string
+	}{
+		{"missing header", "", "Authorization header required"},
+		{"not bearer", "Basic dXNlcjpwYXNz", "Authentication header must be of type Bearer"},
+		{"invalid token", "Bearer not-a-token", "Invalid token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := serve(tokens, tt.authorization)
+
+			assert.Equal(t, http.StatusUnauthorized, rr.Code)
+			assert.Equal(t, tt.body, strings.TrimSpace(rr.Body.String()))
+		})
+	}
+}
+
+func TestAuthMiddleware_RejectsRevokedTokenAndSession(t *testing.T) {
+	tokens := newTestTokens(t)
+	sessionID := uuid.New()
+
+	revokedToken, claims, err := tokens.Issue(token.Claims{UserID: uuid.New(), SessionID: sessionID})
+	require.NoError(t, err)
+	otherToken, _, err := tokens.Issue(token.Claims{UserID: uuid.New(), SessionID: sessionID})
+	require.NoError(t, err)
+
+	revocations := memoryRevocations{}
+	require.NoError(t, revocations.Revoke(claims.ID, claims.ExpiresAt))
+
+	rr, _ := serveWithRevocations(tokens, revocations, "Bearer "+revokedToken)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "Token revoked", strings.TrimSpace(rr.Body.String()))
+
+	rr, _ = serveWithRevocations(tokens, revocations, "Bearer "+otherToken)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	require.NoError(t, revocations.Revoke(sessionID.String(), claims.ExpiresAt))
+
+	rr, _ = serveWithRevocations(tokens, revocations, "Bearer "+otherToken)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+}
+
+func TestPrincipal_CanAccess(t *testing.T) {
+	ownerID := uuid.New()
+
+	owner := &Principal{UserID: ownerID, Roles: []string{"member"}}
+	other := &Principal{UserID: uuid.New(), Roles: []string{"member"}}
+	admin := &Principal{UserID: uuid.New(), Roles: []string{"admin"}}
+
+	assert.True(t, owner.CanAccess(ownerID))
+	assert.False(t, other.CanAccess(ownerID))
+	assert.True(t, admin.CanAccess(ownerID))
+}
+
+func TestAuthMiddleware_APITokens(t *testing.T) {
+	tokens := newTestTokens(t)
+	apiToken := &domain.APIToken{ID: uuid.New(), UserID: uuid.New(), Scopes: "readings:read"}
+	secret := domain.APITokenPrefix + "secret"
+	apiTokens := memoryAPITokens{secret: apiToken}
+
+	rr, principal := serveWith(tokens, memoryRevocations{}, apiTokens, "Bearer "+secret)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, apiToken.UserID, principal.UserID)
+	assert.Equal(t, apiToken.ID, principal.APITokenID)
+	assert.True(t, principal.IsAPIToken())
+	assert.Equal(t, []string{domain.PermissionReadingsRead}, principal.Scopes)
+	assert.Equal(t, []string{domain.RoleMember}, principal.Roles)
+
+	rr, _ = serveWith(tokens, memoryRevocations{}, apiTokens, "Bearer "+domain.APITokenPrefix+"revoked")
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "Invalid token", strings.TrimSpace(rr.Body.String()))
+
+	rr, _ = serve(tokens, "Bearer "+secret)
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, "API tokens are not accepted here", strings.TrimSpace(rr.Body.String()))
+}
+
+func TestPrincipal_Can(t *testing.T) {
+	admin := &Principal{Roles: []string{domain.RoleAdmin}}
+	member := &Principal{Roles: []string{domain.RoleMember}}
+	viewer := &Principal{Roles: []string{domain.RoleViewer}}
+	unknown := &Principal{Roles: []string{"root"}}
+
+	assert.True(t, admin.Can(domain.PermissionUsersManage))
+	assert.False(t, member.Can(domain.PermissionUsersManage))
+	assert.True(t, member.Can(domain.PermissionDevicesWrite))
+	assert.True(t, viewer.Can(domain.PermissionDevicesRead))
+	assert.False(t, viewer.Can(domain.PermissionDevicesWrite))
+	assert.False(t, unknown.Can(domain.PermissionDevicesRead))
+
+	apiToken := &Principal{Roles: []string{domain.RoleAdmin}, APITokenID: uuid.New(), Scopes: []string{domain.PermissionReadingsRead}}
+	assert.True(t, apiToken.Can(domain.PermissionReadingsRead))
+	assert.False(t, apiToken.Can(domain.PermissionDevicesRead))
+
+	viewerToken := &Principal{Roles: []string{domain.RoleViewer}, APITokenID: uuid.New(), Scopes: []string{domain.PermissionDevicesWrite}}
+	assert.False(t, viewerToken.Can(domain.PermissionDevicesWrite))
+}
+
+func TestRequirePermission(t *testing.T) {
+	handler := RequirePermission(domain.PermissionDevicesWrite)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name      string
+		principal *Principal
+		status    int
+	}{
+		{"member", &Principal{UserID: uuid.New(), Roles: []string{domain.RoleMember}}, http.StatusNoContent},
+		{"viewer", &Principal{UserID: uuid.New(), Roles: []string{domain.RoleViewer}}, http.StatusForbidden},
+		{"unauthenticated", nil, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/devices", nil)
+			if tt.principal != nil {
+				req = req.WithContext(WithPrincipal(req.Context(), tt.principal))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.status, rr.Code)
+		})
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		expectedAddr string
+	}{
+		{"direct client", "203.0.113.7:51234", "", "", "203.0.113.7"},
+		{"direct client forging headers", "203.0.113.7:51234", "198.51.100.1", "198.51.100.2", "203.0.113.7"},
+		{"trusted proxy", "10.0.0.2:443", "198.51.100.1", "", "198.51.100.1"},
+		{"client prepending to the chain", "10.0.0.2:443", "192.0.2.99, 198.51.100.1, 10.0.0.3", "", "198.51.100.1"},
+		{"trusted proxy with X-Real-IP", "10.0.0.2:443", "", "198.51.100.1", "198.51.100.1"},
+		{"garbage header", "10.0.0.2:443", "not-an-ip", "", "10.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var remoteAddr string
+			handler := RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				remoteAddr = r.RemoteAddr
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/auth", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, tt.expectedAddr, remoteAddr)
+		})
+	}
+}
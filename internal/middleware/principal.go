@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+// Principal is the caller authenticated by AuthMiddleware.
+type Principal struct {
+	UserID    uuid.UUID
+	Roles     []string
+	TokenID   string
+	SessionID uuid.UUID
+	ExpiresAt time.Time
+	// APITokenID is set when the caller authenticated with a personal API
+	// token, Scopes then limits what the roles grant.
+	APITokenID uuid.UUID
+	Scopes     []string
+}
+
+func (p *Principal) IsAPIToken() bool {
+	return p.APITokenID != uuid.Nil
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.HasRole(domain.RoleAdmin)
+}
+
+// Can reports whether any role of p grants permission, and for API tokens
+// whether the token is scoped to it.
+func (p *Principal) Can(permission string) bool {
+	if p.IsAPIToken() && !slices.Contains(p.Scopes, permission) {
+		return false
+	}
+
+	for _, role := range p.Roles {
+		if domain.RoleHasPermission(role, permission) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// CanAccess reports whether p may read data owned by ownerID, admins may
+// read everyone's.
+func (p *Principal) CanAccess(ownerID uuid.UUID) bool {
+	return p.UserID == ownerID || p.IsAdmin()
+}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, p)
+}
+
+// PrincipalFromContext returns the caller authenticated by AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey).(*Principal)
+	return p, ok
+}
+
+// UserIDFromContext returns the ID of the user authenticated by
+// AuthMiddleware.
+func UserIDFromContext(ctx context.Context) (uuid.UUID, bool) {
+	p, ok := PrincipalFromContext(ctx)
+	if !ok {
+		return uuid.Nil, false
+	}
+
+	return p.UserID, true
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP replaces r.RemoteAddr with the address of the client. Forwarding
+// headers are only honored on requests from trustedProxies, anyone else
+// could set them to dodge the per IP login throttle or forge audit events.
+// X-Forwarded-For is read right to left skipping trusted proxies, so the
+// result is the address the outermost trusted proxy saw and entries a
+// client prepended are ignored.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peer, ok := parseAddr(r.RemoteAddr); ok {
+				client := peer
+				if isTrusted(trustedProxies, peer) {
+					client = forwardedAddr(r, trustedProxies, peer)
+				}
+				r.RemoteAddr = client.String()
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func forwardedAddr(r *http.Request, trustedProxies []netip.Prefix, peer netip.Addr) netip.Addr {
+	if forwardedFor := r.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+
+		client := peer
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, ok := parseAddr(strings.TrimSpace(hops[i]))
+			if !ok {
+				break
+			}
+			client = hop
+			if !isTrusted(trustedProxies, hop) {
+				break
+			}
+		}
+
+		return client
+	}
+
+	if realIP, ok := parseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ok {
+		return realIP
+	}
+
+	return peer
+}
+
+// parseAddr accepts an address with or without a port.
+func parseAddr(value string) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(value); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return addr.Unmap(), true
+}
+
+func isTrusted(trustedProxies []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
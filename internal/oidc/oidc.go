@@ -0,0 +1,303 @@
+// Package oidc is an OpenID Connect relying party for the authorization
+// code flow with PKCE, e.g. "Sign in with Google".
+package oidc
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+var (
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	ErrExchangeFailed = errors.New("authorization code exchange failed")
+)
+
+// keysMaxAge is how long the provider's signing keys are cached, unknown
+// keys trigger a refetch sooner.
+const keysMaxAge = time.Hour
+
+type Config struct {
+	// Name identifies the provider in URLs and linked identities.
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the provider sends the user back with the
+	// authorization code, usually a deep link into the app.
+	RedirectURL string
+	Scopes      []string
+}
+
+// Identity is what the ID token says about the user.
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider discovers its endpoints on first use, so the API starts even
+// when a provider is unreachable.
+type Provider struct {
+	config Config
+	client *http.Client
+	now    func() time.Time
+
+	mu            sync.Mutex
+	metadata      *metadata
+	keys          jwk.Set
+	keysFetchedAt time.Time
+}
+
+func NewProvider(config Config, client *http.Client) (*Provider, error) {
+	if config.Name == "" || config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("OIDC provider %q needs a name, issuer, client ID and redirect URL", config.Name)
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+
+	return &Provider{config: config, client: client, now: time.Now}, nil
+}
+
+func (p *Provider) Name() string {
+	return p.config.Name
+}
+
+// AuthCodeURL returns the URL the user signs in at. state and nonce are
+// echoed back in the redirect and the ID token, codeChallenge is the S256
+// challenge of the verifier later passed to Exchange.
+func (p *Provider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+
+	return meta.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange trades an authorization code for the identity in its ID token,
+// which must carry nonce.
+func (p *Provider) Exchange(code, codeVerifier, nonce string) (*Identity, error) {
+	meta, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+
+	response, err := p.client.PostForm(meta.TokenEndpoint, form)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered %d", ErrExchangeFailed, p.config.Name, response.StatusCode)
+	}
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrExchangeFailed, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: no ID token", ErrExchangeFailed)
+	}
+
+	return p.verify(meta, tokens.IDToken, nonce)
+}
+
+func (p *Provider) verify(meta *metadata, idToken string, nonce string) (*Identity, error) {
+	token, err := p.parse(meta, idToken, false)
+	if err != nil {
+		// The provider may have rotated its keys since they were cached.
+		token, err = p.parse(meta, idToken, true)
+	}
+	if err != nil {
+		return nil, ErrInvalidIDToken
+	}
+
+	if claimString(token, "nonce") != nonce || token.Subject() == "" {
+		return nil, ErrInvalidIDToken
+	}
+
+	return &Identity{
+		Subject:       token.Subject(),
+		Email:         claimString(token, "email"),
+		EmailVerified: claimBool(token, "email_verified"),
+		GivenName:     claimString(token, "given_name"),
+		FamilyName:    claimString(token, "family_name"),
+	}, nil
+}
+
+func (p *Provider) parse(meta *metadata, idToken string, refresh bool) (jwt.Token, error) {
+	keys, err := p.signingKeys(meta, refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	return jwt.ParseString(idToken,
+		jwt.WithKeySet(keys, jws.WithInferAlgorithmFromKey(true)),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithClock(jwt.ClockFunc(p.now)),
+		jwt.WithAcceptableSkew(time.Minute),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	)
+}
+
+func (p *Provider) discover() (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	var meta metadata
+	if err := p.getJSON(p.config.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("OIDC discovery for %s failed: %w", p.config.Name, err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != p.config.Issuer {
+		return nil, fmt.Errorf("OIDC discovery for %s returned issuer %q", p.config.Name, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("OIDC discovery for %s is missing endpoints", p.config.Name)
+	}
+
+	p.metadata = &meta
+
+	return p.metadata, nil
+}
+
+func (p *Provider) signingKeys(meta *metadata, refresh bool) (jwk.Set, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.keys != nil && !refresh && p.now().Sub(p.keysFetchedAt) < keysMaxAge {
+		return p.keys, nil
+	}
+
+	response, err := p.client.Get(meta.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s keys answered %d", p.config.Name, response.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := jwk.Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	p.keys = keys
+	p.keysFetchedAt = p.now()
+
+	return keys, nil
+}
+
+func (p *Provider) getJSON(url string, v interface{}) error {
+	response, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %d", url, response.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, 1<<20)).Decode(v)
+}
+
+// CodeChallenge returns the S256 PKCE challenge of verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func claimString(token jwt.Token, name string) string {
+	value, ok := token.Get(name)
+	if !ok {
+		return ""
+	}
+
+	s, _ := value.(string)
+	return s
+}
+
+// claimBool also accepts "true", some providers send booleans as strings.
+func claimBool(token jwt.Token, name string) bool {
+	value, ok := token.Get(name)
+	if !ok {
+		return false
+	}
+
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	}
+
+	return false
+}
@@ -0,0 +1,85 @@
+package oidc_test
+
+import (
+	"testing"
+
+	"github.com/azevedoguigo/thermosync-api/internal/oidc"
+	"github.com/azevedoguigo/thermosync-api/internal/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var senna = oidc.Identity{
+	Subject:       "senna-1988",
+	Email:         "senna@example.com",
+	EmailVerified: true,
+	GivenName:     "Ayrton",
+	FamilyName:    "Senna",
+}
+
+func newTestProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	server := oidctest.NewServer()
+	t.Cleanup(server.Close)
+
+	provider, err := oidc.NewProvider(server.Config("test"), nil)
+	require.NoError(t, err)
+
+	return provider, server
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", oidc.CodeChallenge("verifier-1"))
+	require.NoError(t, err)
+	assert.Contains(t, authURL, server.URL+"/authorize?")
+
+	code, state, err := server.SignIn(authURL, senna)
+	require.NoError(t, err)
+	assert.Equal(t, "state-1", state)
+
+	identity, err := provider.Exchange(code, "verifier-1", "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, senna, *identity)
+
+	_, err = provider.Exchange(code, "verifier-1", "nonce-1")
+	assert.ErrorIs(t, err, oidc.ErrExchangeFailed, "codes work once")
+}
+
+func TestProvider_Exchange_WrongVerifier(t *testing.T) {
+	provider, server := newTestProvider(t)
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", oidc.CodeChallenge("verifier-1"))
+	require.NoError(t, err)
+	code, _, err := server.SignIn(authURL, senna)
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(code, "another-verifier", "nonce-1")
+
+	assert.ErrorIs(t, err, oidc.ErrExchangeFailed)
+}
+
+func TestProvider_Exchange_WrongNonce(t *testing.T) {
+	provider, server := newTestProvider(t)
+	server.Nonce = "replayed-nonce"
+
+	authURL, err := provider.AuthCodeURL("state-1", "nonce-1", oidc.CodeChallenge("verifier-1"))
+	require.NoError(t, err)
+	code, _, err := server.SignIn(authURL, senna)
+	require.NoError(t, err)
+
+	_, err = provider.Exchange(code, "verifier-1", "nonce-1")
+
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+}
+
+func TestNewProvider_RequiresConfig(t *testing.T) {
+	_, err := oidc.NewProvider(oidc.Config{Name: "test"}, nil)
+
+	assert.Error(t, err)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", oidc.CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
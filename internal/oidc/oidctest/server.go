@@ -0,0 +1,182 @@
+// Package oidctest runs a fake OpenID Connect provider for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/oidc"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+const ClientID = "thermosync-test"
+
+type authorization struct {
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	identity      oidc.Identity
+}
+
+// Server issues authorization codes for whatever identity a test signs in
+// as and exchanges them for RS256 ID tokens.
+type Server struct {
+	*httptest.Server
+
+	key  jwk.Key
+	keys jwk.Set
+
+	mu             sync.Mutex
+	authorizations map[string]authorization
+	// Nonce, when set, replaces the nonce of the next ID tokens.
+	Nonce string
+}
+
+func NewServer() *Server {
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		panic(err)
+	}
+	key.Set(jwk.KeyIDKey, "oidctest")
+	key.Set(jwk.AlgorithmKey, jwa.RS256)
+
+	public, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		panic(err)
+	}
+	keys := jwk.NewSet()
+	keys.AddKey(public)
+
+	s := &Server{key: key, keys: keys, authorizations: make(map[string]authorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/jwks", s.jwks)
+	mux.HandleFunc("/token", s.token)
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Config returns the provider config of a client of this server.
+func (s *Server) Config(name string) oidc.Config {
+	return oidc.Config{
+		Name:        name,
+		Issuer:      s.URL,
+		ClientID:    ClientID,
+		RedirectURL: "thermosync://oidc/callback",
+	}
+}
+
+// SignIn plays the user signing in as identity at authURL and returns the
+// code and state the provider redirects back with.
+func (s *Server) SignIn(authURL string, identity oidc.Identity) (string, string, error) {
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := parsed.Query()
+	if query.Get("client_id") != ClientID || query.Get("response_type") != "code" {
+		return "", "", errors.New("oidctest: invalid authorization request")
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		return "", "", errors.New("oidctest: PKCE is required")
+	}
+
+	code := uuid.NewString()
+
+	s.mu.Lock()
+	s.authorizations[code] = authorization{
+		redirectURI:   query.Get("redirect_uri"),
+		nonce:         query.Get("nonce"),
+		codeChallenge: query.Get("code_challenge"),
+		identity:      identity,
+	}
+	s.mu.Unlock()
+
+	return code, query.Get("state"), nil
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{
+		"issuer":                 s.URL,
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(s.keys)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	auth, ok := s.authorizations[r.PostForm.Get("code")]
+	delete(s.authorizations, r.PostForm.Get("code"))
+	nonce := s.Nonce
+	s.mu.Unlock()
+
+	if !ok ||
+		r.PostForm.Get("client_id") != ClientID ||
+		r.PostForm.Get("redirect_uri") != auth.redirectURI ||
+		oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != auth.codeChallenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	if nonce == "" {
+		nonce = auth.nonce
+	}
+
+	now := time.Now()
+	token, err := jwt.NewBuilder().
+		Issuer(s.URL).
+		Subject(auth.identity.Subject).
+		Audience([]string{ClientID}).
+		IssuedAt(now).
+		Expiration(now.Add(5*time.Minute)).
+		Claim("nonce", nonce).
+		Claim("email", auth.identity.Email).
+		Claim("email_verified", auth.identity.EmailVerified).
+		Claim("given_name", auth.identity.GivenName).
+		Claim("family_name", auth.identity.FamilyName).
+		Build()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(jwa.RS256, s.key))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": uuid.NewString(),
+		"token_type":   "Bearer",
+		"id_token":     string(signed),
+	})
+}
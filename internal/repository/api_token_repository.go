@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type APITokenRepository interface {
+	Create(apiToken *domain.APIToken) error
+	FindByHash(tokenHash string) (*domain.APIToken, error)
+	FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.APIToken, error)
+	Touch(id uuid.UUID, lastUsedAt time.Time) error
+	// Revoke reports false when the user has no unrevoked token with id.
+	Revoke(userID, id uuid.UUID, revokedAt time.Time) (bool, error)
+	RevokeAllByUserID(userID uuid.UUID, revokedAt time.Time) error
+}
+
+type apiTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewAPITokenRepository(db *gorm.DB) APITokenRepository {
+	return &apiTokenRepository{db: db}
+}
+
+func (r *apiTokenRepository) Create(apiToken *domain.APIToken) error {
+	return r.db.Create(apiToken).Error
+}
+
+func (r *apiTokenRepository) FindByHash(tokenHash string) (*domain.APIToken, error) {
+	var apiToken domain.APIToken
+
+	err := r.db.Where("token_hash = ?", tokenHash).First(&apiToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &apiToken, nil
+}
+
+func (r *apiTokenRepository) FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.APIToken, error) {
+	var apiTokens []domain.APIToken
+
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+		Order("created_at DESC").
+		Find(&apiTokens).Error
+
+	return apiTokens, err
+}
+
+func (r *apiTokenRepository) Touch(id uuid.UUID, lastUsedAt time.Time) error {
+	return r.db.Model(&domain.APIToken{}).Where("id = ?", id).Update("last_used_at", lastUsedAt).Error
+}
+
+func (r *apiTokenRepository) Revoke(userID, id uuid.UUID, revokedAt time.Time) (bool, error) {
+	result := r.db.Model(&domain.APIToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", revokedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *apiTokenRepository) RevokeAllByUserID(userID uuid.UUID, revokedAt time.Time) error {
+	return r.db.Model(&domain.APIToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", revokedAt).Error
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type AuditRepository interface {
+	Create(event *domain.AuditEvent) error
+}
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func NewAuditRepository(db *gorm.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+func (r *auditRepository) Create(event *domain.AuditEvent) error {
+	return r.db.Create(event).Error
+}
@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DeviceRepository interface {
+	Create(device *domain.Device) error
+	Update(device *domain.Device) error
+	FindByID(id uuid.UUID) (*domain.Device, error)
+	FindActiveBySerial(serial string) (*domain.Device, error)
+	FindActiveByUserID(userID uuid.UUID) ([]domain.Device, error)
+	// FindByRoomIDs returns the devices assigned to the rooms, retired ones
+	// included so their history still counts.
+	FindByRoomIDs(roomIDs []uuid.UUID) ([]domain.Device, error)
+	UpdateLastSeen(id uuid.UUID, lastSeenAt time.Time) error
+}
+
+type deviceRepository struct {
+	db *gorm.DB
+}
+
+func NewDeviceRepository(db *gorm.DB) DeviceRepository {
+	return &deviceRepository{db: db}
+}
+
+func (r *deviceRepository) Create(device *domain.Device) error {
+	return r.db.Create(device).Error
+}
+
+func (r *deviceRepository) Update(device *domain.Device) error {
+	return r.db.Save(device).Error
+}
+
+func (r *deviceRepository) FindByID(id uuid.UUID) (*domain.Device, error) {
+	var device domain.Device
+
+	err := r.db.First(&device, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+func (r *deviceRepository) FindActiveBySerial(serial string) (*domain.Device, error) {
+	var device domain.Device
+
+	err := r.db.Where("serial = ? AND retired_at IS NULL", serial).First(&device).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &device, nil
+}
+
+func (r *deviceRepository) FindActiveByUserID(userID uuid.UUID) ([]domain.Device, error) {
+	var devices []domain.Device
+
+	err := r.db.Where("user_id = ? AND retired_at IS NULL", userID).Order("created_at").Find(&devices).Error
+
+	return devices, err
+}
+
+func (r *deviceRepository) FindByRoomIDs(roomIDs []uuid.UUID) ([]domain.Device, error) {
+	var devices []domain.Device
+	if len(roomIDs) == 0 {
+		return devices, nil
+	}
+
+	err := r.db.Where("room_id IN ?", roomIDs).Order("created_at").Find(&devices).Error
+
+	return devices, err
+}
+
+func (r *deviceRepository) UpdateLastSeen(id uuid.UUID, lastSeenAt time.Time) error {
+	return r.db.Model(&domain.Device{}).Where("id = ?", id).Update("last_seen_at", lastSeenAt).Error
+}
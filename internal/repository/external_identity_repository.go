@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"gorm.io/gorm"
+)
+
+type ExternalIdentityRepository interface {
+	Create(identity *domain.ExternalIdentity) error
+	FindBySubject(provider, subject string) (*domain.ExternalIdentity, error)
+	CreateLoginState(state *domain.OIDCLoginState) error
+	// ConsumeLoginState deletes and returns the login state with stateHash,
+	// so each state completes one login.
+	ConsumeLoginState(stateHash string) (*domain.OIDCLoginState, error)
+	DeleteExpiredLoginStates(now time.Time) error
+}
+
+type externalIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewExternalIdentityRepository(db *gorm.DB) ExternalIdentityRepository {
+	return &externalIdentityRepository{db: db}
+}
+
+func (r *externalIdentityRepository) Create(identity *domain.ExternalIdentity) error {
+	return r.db.Create(identity).Error
+}
+
+func (r *externalIdentityRepository) FindBySubject(provider, subject string) (*domain.ExternalIdentity, error) {
+	var identity domain.ExternalIdentity
+
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *externalIdentityRepository) CreateLoginState(state *domain.OIDCLoginState) error {
+	return r.db.Create(state).Error
+}
+
+func (r *externalIdentityRepository) ConsumeLoginState(stateHash string) (*domain.OIDCLoginState, error) {
+	var state domain.OIDCLoginState
+
+	err := r.db.Where("state_hash = ?", stateHash).First(&state).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := r.db.Delete(&domain.OIDCLoginState{}, "id = ?", state.ID)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	return &state, nil
+}
+
+func (r *externalIdentityRepository) DeleteExpiredLoginStates(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&domain.OIDCLoginState{}).Error
+}
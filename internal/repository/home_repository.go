@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type HomeRepository interface {
+	CreateHome(home *domain.Home) error
+	UpdateHome(home *domain.Home) error
+	FindHomeByID(id uuid.UUID) (*domain.Home, error)
+	// FindHomesByUserID returns the homes the user owns or is a member of.
+	FindHomesByUserID(userID uuid.UUID) ([]domain.Home, error)
+	// DeleteHome removes the home with its rooms and members, their
+	// devices are kept but no longer assigned to a room.
+	DeleteHome(id uuid.UUID) error
+
+	CreateRoom(room *domain.Room) error
+	UpdateRoom(room *domain.Room) error
+	FindRoomByID(id uuid.UUID) (*domain.Room, error)
+	FindRoomsByHomeID(homeID uuid.UUID) ([]domain.Room, error)
+	// DeleteRoom removes the room, its devices are kept but no longer
+	// assigned to a room.
+	DeleteRoom(id uuid.UUID) error
+
+	FindMember(homeID, userID uuid.UUID) (*domain.HomeMember, error)
+	FindMembers(homeID uuid.UUID) ([]domain.HomeMember, error)
+	SaveMember(member *domain.HomeMember) error
+	DeleteMember(homeID, userID uuid.UUID) error
+}
+
+type homeRepository struct {
+	db *gorm.DB
+}
+
+func NewHomeRepository(db *gorm.DB) HomeRepository {
+	return &homeRepository{db: db}
+}
+
+func (r *homeRepository) CreateHome(home *domain.Home) error {
+	return r.db.Create(home).Error
+}
+
+func (r *homeRepository) UpdateHome(home *domain.Home) error {
+	return r.db.Save(home).Error
+}
+
+func (r *homeRepository) FindHomeByID(id uuid.UUID) (*domain.Home, error) {
+	var home domain.Home
+
+	err := r.db.First(&home, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &home, nil
+}
+
+func (r *homeRepository) FindHomesByUserID(userID uuid.UUID) ([]domain.Home, error) {
+	var homes []domain.Home
+
+	memberships := r.db.Model(&domain.HomeMember{}).Select("home_id").Where("user_id = ?", userID)
+
+	err := r.db.Where("user_id = ? OR id IN (?)", userID, memberships).Order("created_at").Find(&homes).Error
+
+	return homes, err
+}
+
+func (r *homeRepository) DeleteHome(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		rooms := tx.Model(&domain.Room{}).Select("id").Where("home_id = ?", id)
+
+		if err := tx.Model(&domain.Device{}).Where("room_id IN (?)", rooms).Update("room_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("home_id = ?", id).Delete(&domain.Room{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("home_id = ?", id).Delete(&domain.HomeMember{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&domain.Home{}, "id = ?", id).Error
+	})
+}
+
+func (r *homeRepository) CreateRoom(room *domain.Room) error {
+	return r.db.Create(room).Error
+}
+
+func (r *homeRepository) UpdateRoom(room *domain.Room) error {
+	return r.db.Save(room).Error
+}
+
+func (r *homeRepository) FindRoomByID(id uuid.UUID) (*domain.Room, error) {
+	var room domain.Room
+
+	err := r.db.First(&room, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &room, nil
+}
+
+func (r *homeRepository) FindRoomsByHomeID(homeID uuid.UUID) ([]domain.Room, error) {
+	var rooms []domain.Room
+
+	err := r.db.Where("home_id = ?", homeID).Order("created_at").Find(&rooms).Error
+
+	return rooms, err
+}
+
+func (r *homeRepository) DeleteRoom(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&domain.Device{}).Where("room_id = ?", id).Update("room_id", nil).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&domain.Room{}, "id = ?", id).Error
+	})
+}
+
+func (r *homeRepository) FindMember(homeID, userID uuid.UUID) (*domain.HomeMember, error) {
+	var member domain.HomeMember
+
+	err := r.db.First(&member, "home_id = ? AND user_id = ?", homeID, userID).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+func (r *homeRepository) FindMembers(homeID uuid.UUID) ([]domain.HomeMember, error) {
+	var members []domain.HomeMember
+
+	err := r.db.Where("home_id = ?", homeID).Order("created_at").Find(&members).Error
+
+	return members, err
+}
+
+func (r *homeRepository) SaveMember(member *domain.HomeMember) error {
+	return r.db.Save(member).Error
+}
+
+func (r *homeRepository) DeleteMember(homeID, userID uuid.UUID) error {
+	return r.db.Delete(&domain.HomeMember{}, "home_id = ? AND user_id = ?", homeID, userID).Error
+}
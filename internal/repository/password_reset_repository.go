@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepository interface {
+	Create(resetToken *domain.PasswordResetToken) error
+	FindByHash(tokenHash string) (*domain.PasswordResetToken, error)
+	// MarkUsed reports false when the token was already used, so a reset
+	// link can't be followed twice concurrently.
+	MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error)
+	// InvalidateByUserID marks every unused token of the user used, once
+	// the password is reset older links must stop working.
+	InvalidateByUserID(userID uuid.UUID, usedAt time.Time) error
+}
+
+type passwordResetRepository struct {
+	db *gorm.DB
+}
+
+func NewPasswordResetRepository(db *gorm.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(resetToken *domain.PasswordResetToken) error {
+	return r.db.Create(resetToken).Error
+}
+
+func (r *passwordResetRepository) FindByHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	var resetToken domain.PasswordResetToken
+
+	err := r.db.Where("token_hash = ?", tokenHash).First(&resetToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &resetToken, nil
+}
+
+func (r *passwordResetRepository) MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&domain.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *passwordResetRepository) InvalidateByUserID(userID uuid.UUID, usedAt time.Time) error {
+	return r.db.Model(&domain.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", usedAt).Error
+}
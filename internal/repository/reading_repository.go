@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReadingRepository interface {
+	Create(readings []domain.Reading) error
+	// Aggregate buckets the readings of the devices, every device weighs
+	// the same in Avg however often it reports.
+	Aggregate(deviceIDs []uuid.UUID, metric string, from, to time.Time, bucket time.Duration) ([]domain.ReadingAggregate, error)
+	// Latest returns the last reading of each device recorded since.
+	Latest(deviceIDs []uuid.UUID, metric string, since time.Time) ([]domain.Reading, error)
+}
+
+type readingRepository struct {
+	db *gorm.DB
+}
+
+func NewReadingRepository(db *gorm.DB) ReadingRepository {
+	return &readingRepository{db: db}
+}
+
+func (r *readingRepository) Create(readings []domain.Reading) error {
+	return r.db.Create(&readings).Error
+}
+
+func (r *readingRepository) Aggregate(deviceIDs []uuid.UUID, metric string, from, to time.Time, bucket time.Duration) ([]domain.ReadingAggregate, error) {
+	var aggregates []domain.ReadingAggregate
+	if len(deviceIDs) == 0 {
+		return aggregates, nil
+	}
+
+	seconds := int64(bucket.Seconds())
+
+	perDevice := r.db.Model(&domain.Reading{}).
+		Select(`device_id, to_timestamp(floor(extract(epoch from recorded_at) / ?) * ?) AS bucket_start,
+			min(value) AS min, max(value) AS max, avg(value) AS avg, count(*) AS count`, seconds, seconds).
+		Where("device_id IN ? AND metric = ? AND recorded_at >= ? AND recorded_at < ?", deviceIDs, metric, from, to).
+		Group("device_id, bucket_start")
+
+	err := r.db.Table("(?) AS per_device", perDevice).
+		Select("bucket_start, min(min) AS min, max(max) AS max, avg(avg) AS avg, sum(count) AS count").
+		Group("bucket_start").
+		Order("bucket_start").
+		Scan(&aggregates).Error
+
+	return aggregates, err
+}
+
+func (r *readingRepository) Latest(deviceIDs []uuid.UUID, metric string, since time.Time) ([]domain.Reading, error) {
+	var readings []domain.Reading
+	if len(deviceIDs) == 0 {
+		return readings, nil
+	}
+
+	err := r.db.Select("DISTINCT ON (device_id) *").
+		Where("device_id IN ? AND metric = ? AND recorded_at >= ?", deviceIDs, metric, since).
+		Order("device_id, recorded_at DESC").
+		Find(&readings).Error
+
+	return readings, err
+}
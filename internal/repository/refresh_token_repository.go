@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepository interface {
+	Create(refreshToken *domain.RefreshToken) error
+	FindByHash(tokenHash string) (*domain.RefreshToken, error)
+	// MarkUsed reports false when the token was already used, so two
+	// concurrent refreshes with the same token can't both succeed.
+	MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error)
+}
+
+type refreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewRefreshTokenRepository(db *gorm.DB) RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(refreshToken *domain.RefreshToken) error {
+	return r.db.Create(refreshToken).Error
+}
+
+func (r *refreshTokenRepository) FindByHash(tokenHash string) (*domain.RefreshToken, error) {
+	var refreshToken domain.RefreshToken
+
+	err := r.db.Where("token_hash = ?", tokenHash).First(&refreshToken).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &refreshToken, nil
+}
+
+func (r *refreshTokenRepository) MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error) {
+	result := r.db.Model(&domain.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", usedAt)
+
+	return result.RowsAffected == 1, result.Error
+}
@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevocationRepository is the token.RevocationStore consulted on every
+// authenticated request.
+type RevocationRepository interface {
+	Revoke(id string, expiresAt time.Time) error
+	IsRevoked(ids ...string) (bool, error)
+	DeleteExpired(now time.Time) error
+}
+
+type revocationRepository struct {
+	db *gorm.DB
+}
+
+func NewRevocationRepository(db *gorm.DB) RevocationRepository {
+	return &revocationRepository{db: db}
+}
+
+func (r *revocationRepository) Revoke(id string, expiresAt time.Time) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at"}),
+	}).Create(&domain.RevokedToken{ID: id, ExpiresAt: expiresAt}).Error
+}
+
+func (r *revocationRepository) IsRevoked(ids ...string) (bool, error) {
+	var count int64
+
+	err := r.db.Model(&domain.RevokedToken{}).Where("id IN ?", ids).Count(&count).Error
+
+	return count > 0, err
+}
+
+func (r *revocationRepository) DeleteExpired(now time.Time) error {
+	return r.db.Where("expires_at <= ?", now).Delete(&domain.RevokedToken{}).Error
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SessionRepository interface {
+	Create(session *domain.Session) error
+	FindByID(id uuid.UUID) (*domain.Session, error)
+	FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.Session, error)
+	Touch(id uuid.UUID, lastUsedAt time.Time, expiresAt time.Time) error
+	Revoke(id uuid.UUID, revokedAt time.Time) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *domain.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id uuid.UUID) (*domain.Session, error) {
+	var session domain.Session
+
+	err := r.db.First(&session, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.Session, error) {
+	var sessions []domain.Session
+
+	err := r.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_used_at DESC").
+		Find(&sessions).Error
+
+	return sessions, err
+}
+
+func (r *sessionRepository) Touch(id uuid.UUID, lastUsedAt time.Time, expiresAt time.Time) error {
+	return r.db.Model(&domain.Session{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_used_at": lastUsedAt,
+		"expires_at":   expiresAt,
+	}).Error
+}
+
+func (r *sessionRepository) Revoke(id uuid.UUID, revokedAt time.Time) error {
+	return r.db.Model(&domain.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", revokedAt).Error
+}
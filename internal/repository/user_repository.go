@@ -1,6 +1,9 @@
 package repository
 
 import (
+	"strings"
+	"time"
+
 	"github.com/azevedoguigo/thermosync-api/internal/domain"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -8,8 +11,19 @@ import (
 
 type UserRepository interface {
 	Create(user *domain.User) error
+	Update(user *domain.User) error
 	FindByEmail(email string) (*domain.User, error)
 	FindByID(id uuid.UUID) (*domain.User, error)
+	// List returns a page of users whose email contains email, oldest
+	// first, and how many match in total.
+	List(email string, offset, limit int) ([]domain.User, int64, error)
+	// UseTOTPStep records step as the last accepted TOTP time step if it is
+	// newer than the stored one and reports whether it was, so a code
+	// can't be used twice concurrently.
+	UseTOTPStep(id uuid.UUID, step int64) (bool, error)
+	// Delete removes the user with everything they own and revokes their
+	// sessions, all or nothing.
+	Delete(id uuid.UUID) error
 }
 
 type userRepository struct {
@@ -24,6 +38,10 @@ func (r *userRepository) Create(user *domain.User) error {
 	return r.db.Create(user).Error
 }
 
+func (r *userRepository) Update(user *domain.User) error {
+	return r.db.Save(user).Error
+}
+
 func (r *userRepository) FindByID(id uuid.UUID) (*domain.User, error) {
 	var user domain.User
 	err := r.db.First(&user, "id = ?", id).Error
@@ -40,3 +58,73 @@ func (r *userRepository) FindByEmail(email string) (*domain.User, error) {
 
 	return &user, nil
 }
+
+func (r *userRepository) List(email string, offset, limit int) ([]domain.User, int64, error) {
+	query := r.db.Model(&domain.User{})
+	if email != "" {
+		escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(email)
+		query = query.Where("email ILIKE ?", "%"+escaped+"%")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var users []domain.User
+	err := query.Order("created_at, id").Offset(offset).Limit(limit).Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (r *userRepository) UseTOTPStep(id uuid.UUID, step int64) (bool, error) {
+	result := r.db.Model(&domain.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *userRepository) Delete(id uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		// Access tokens outlive the session rows, keep rejecting them
+		// until they expire.
+		err := tx.Exec(`INSERT INTO revoked_tokens (id, expires_at)
+			SELECT id::text, expires_at FROM sessions
+			WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+			ON CONFLICT (id) DO NOTHING`, id, time.Now()).Error
+		if err != nil {
+			return err
+		}
+
+		// Homes shared with the user and everyone's access to the user's
+		// own homes go with them.
+		homes := tx.Model(&domain.Home{}).Select("id").Where("user_id = ?", id)
+		if err := tx.Where("user_id = ? OR home_id IN (?)", id, homes).Delete(&domain.HomeMember{}).Error; err != nil {
+			return err
+		}
+
+		owned := []interface{}{
+			&domain.Reading{},
+			&domain.Device{},
+			&domain.Room{},
+			&domain.Home{},
+			&domain.RefreshToken{},
+			&domain.PasswordResetToken{},
+			&domain.RecoveryCode{},
+			&domain.APIToken{},
+			&domain.ExternalIdentity{},
+			&domain.Session{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&domain.User{}, "id = ?", id).Error
+	})
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrActOnSelf keeps admins from demoting or deleting themselves through
+// the admin routes, so there is always an admin left to undo mistakes.
+var ErrActOnSelf = errors.New("admins can't change their own role or delete themselves")
+
+// SessionRevoker signs a user out everywhere, AuthService implements it.
+type SessionRevoker interface {
+	LogoutAll(userID uuid.UUID) error
+}
+
+// AdminService lets support staff manage users. Every change is recorded
+// as an audit event naming the admin who made it.
+type AdminService interface {
+	ListUsers(query *contract.ListUsersQueryDTO) (*contract.UserPageDTO, error)
+	FindUser(id uuid.UUID) (*domain.User, error)
+	SetRole(actorID uuid.UUID, id uuid.UUID, roleDTO *contract.SetRoleDTO) (*domain.User, error)
+	RevokeSessions(actorID uuid.UUID, id uuid.UUID) error
+	DeleteUser(actorID uuid.UUID, id uuid.UUID) error
+}
+
+type adminService struct {
+	userRepo  repository.UserRepository
+	auditRepo repository.AuditRepository
+	sessions  SessionRevoker
+	now       func() time.Time
+}
+
+func NewAdminService(userRepo repository.UserRepository, auditRepo repository.AuditRepository, sessions SessionRevoker) AdminService {
+	return &adminService{userRepo: userRepo, auditRepo: auditRepo, sessions: sessions, now: time.Now}
+}
+
+func (s *adminService) ListUsers(query *contract.ListUsersQueryDTO) (*contract.UserPageDTO, error) {
+	if err := pkg.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.userRepo.List(query.Email, (query.Page-1)*query.Limit, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &contract.UserPageDTO{
+		Users: make([]contract.UserResponseDTO, 0, len(users)),
+		Page:  query.Page,
+		Limit: query.Limit,
+		Total: total,
+	}
+	for i := range users {
+		page.Users = append(page.Users, contract.NewUserResponseDTO(&users[i]))
+	}
+
+	return page, nil
+}
+
+func (s *adminService) FindUser(id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetRole changes the role of the user and signs them out, so tokens
+// carrying the old role stop working right away.
+func (s *adminService) SetRole(actorID uuid.UUID, id uuid.UUID, roleDTO *contract.SetRoleDTO) (*domain.User, error) {
+	if err := pkg.ValidateStruct(roleDTO); err != nil {
+		return nil, err
+	}
+	if actorID == id {
+		return nil, ErrActOnSelf
+	}
+
+	user, err := s.FindUser(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == roleDTO.Role {
+		return user, nil
+	}
+
+	previous := user.Role
+	user.Role = roleDTO.Role
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	if err := s.sessions.LogoutAll(user.ID); err != nil {
+		return nil, err
+	}
+
+	s.audit(domain.AuditAdminRoleChanged, actorID, user, previous+" -> "+user.Role)
+
+	return user, nil
+}
+
+func (s *adminService) RevokeSessions(actorID uuid.UUID, id uuid.UUID) error {
+	user, err := s.FindUser(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.sessions.LogoutAll(user.ID); err != nil {
+		return err
+	}
+
+	s.audit(domain.AuditAdminSessionsEnded, actorID, user, "")
+
+	return nil
+}
+
+func (s *adminService) DeleteUser(actorID uuid.UUID, id uuid.UUID) error {
+	if actorID == id {
+		return ErrActOnSelf
+	}
+
+	user, err := s.FindUser(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Delete(user.ID); err != nil {
+		return err
+	}
+
+	s.audit(domain.AuditAdminUserDeleted, actorID, user, "")
+
+	return nil
+}
+
+func (s *adminService) audit(eventType string, actorID uuid.UUID, user *domain.User, detail string) {
+	event := &domain.AuditEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		UserID:    &user.ID,
+		ActorID:   &actorID,
+		Email:     user.Email,
+		Detail:    detail,
+		CreatedAt: s.now(),
+	}
+
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("Failed to record %s audit event: %s", eventType, err)
+	}
+}
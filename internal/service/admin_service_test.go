@@ -0,0 +1,135 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockSessionRevoker struct {
+	mock.Mock
+}
+
+func (m *mockSessionRevoker) LogoutAll(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+type adminServiceMocks struct {
+	users    *mockUserRepository
+	audit    *mockAuditRepository
+	sessions *mockSessionRevoker
+}
+
+func newTestAdminService() (AdminService, *adminServiceMocks) {
+	mocks := &adminServiceMocks{
+		users:    new(mockUserRepository),
+		audit:    new(mockAuditRepository),
+		sessions: new(mockSessionRevoker),
+	}
+	mocks.audit.On("Create", mock.Anything).Return(nil)
+
+	return NewAdminService(mocks.users, mocks.audit, mocks.sessions), mocks
+}
+
+func TestAdminService_ListUsers(t *testing.T) {
+	users := []domain.User{{ID: uuid.New(), Email: "senna@example.com", Password: "hash"}}
+
+	adminService, mocks := newTestAdminService()
+	mocks.users.On("List", "senna", 20, 10).Return(users, int64(21), nil)
+
+	page, err := adminService.ListUsers(&contract.ListUsersQueryDTO{Email: "senna", Page: 3, Limit: 10})
+
+	require.NoError(t, err)
+	assert.Equal(t, int64(21), page.Total)
+	assert.Equal(t, 3, page.Page)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, "senna@example.com", page.Users[0].Email)
+}
+
+func TestAdminService_ListUsers_InvalidPage(t *testing.T) {
+	adminService, mocks := newTestAdminService()
+
+	_, err := adminService.ListUsers(&contract.ListUsersQueryDTO{Page: 1, Limit: 1000})
+
+	assert.Error(t, err)
+	mocks.users.AssertNotCalled(t, "List", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAdminService_SetRole(t *testing.T) {
+	actorID := uuid.New()
+	user := newTestUser(t, "supersenha")
+
+	adminService, mocks := newTestAdminService()
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("Update", user).Return(nil)
+	mocks.sessions.On("LogoutAll", user.ID).Return(nil)
+
+	updated, err := adminService.SetRole(actorID, user.ID, &contract.SetRoleDTO{Role: domain.RoleViewer})
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.RoleViewer, updated.Role)
+	mocks.sessions.AssertCalled(t, "LogoutAll", user.ID)
+
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Equal(t, domain.AuditAdminRoleChanged, event.Type)
+	assert.Equal(t, actorID, *event.ActorID)
+	assert.Equal(t, user.ID, *event.UserID)
+	assert.Equal(t, "member -> viewer", event.Detail)
+}
+
+func TestAdminService_SetRole_Rejected(t *testing.T) {
+	actorID := uuid.New()
+	missingID := uuid.New()
+
+	adminService, mocks := newTestAdminService()
+	mocks.users.On("FindByID", missingID).Return(nil, gorm.ErrRecordNotFound)
+
+	_, err := adminService.SetRole(actorID, uuid.New(), &contract.SetRoleDTO{Role: "root"})
+	assert.Error(t, err)
+
+	_, err = adminService.SetRole(actorID, actorID, &contract.SetRoleDTO{Role: domain.RoleMember})
+	assert.ErrorIs(t, err, ErrActOnSelf)
+
+	_, err = adminService.SetRole(actorID, missingID, &contract.SetRoleDTO{Role: domain.RoleMember})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	mocks.users.AssertNotCalled(t, "Update", mock.Anything)
+	mocks.audit.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestAdminService_RevokeSessions(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	adminService, mocks := newTestAdminService()
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.sessions.On("LogoutAll", user.ID).Return(nil)
+
+	err := adminService.RevokeSessions(uuid.New(), user.ID)
+
+	assert.NoError(t, err)
+	mocks.sessions.AssertCalled(t, "LogoutAll", user.ID)
+	mocks.audit.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestAdminService_DeleteUser(t *testing.T) {
+	actorID := uuid.New()
+	user := newTestUser(t, "supersenha")
+
+	adminService, mocks := newTestAdminService()
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("Delete", user.ID).Return(nil)
+
+	assert.ErrorIs(t, adminService.DeleteUser(actorID, actorID), ErrActOnSelf)
+	assert.NoError(t, adminService.DeleteUser(actorID, user.ID))
+
+	mocks.users.AssertNumberOfCalls(t, "Delete", 1)
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Equal(t, domain.AuditAdminUserDeleted, event.Type)
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAPITokenNotFound = errors.New("api token not found")
+	ErrScopeNotGranted  = errors.New("scope is not granted by your role")
+)
+
+// apiTokenTouchInterval limits how often LastUsedAt is written, scripts
+// may call the API several times per second.
+const apiTokenTouchInterval = time.Minute
+
+// APITokenService manages the personal API tokens users create for
+// scripts and integrations.
+type APITokenService interface {
+	CreateToken(userID uuid.UUID, tokenDTO *contract.NewAPITokenDTO) (*domain.APIToken, string, error)
+	ListTokens(userID uuid.UUID) ([]domain.APIToken, error)
+	RevokeToken(userID, id uuid.UUID) error
+	// AuthenticateAPIToken returns the token and the current roles of its
+	// owner, or token.ErrInvalidToken when it is unknown, revoked or
+	// expired.
+	AuthenticateAPIToken(secret string) (*domain.APIToken, []string, error)
+}
+
+type apiTokenService struct {
+	userRepo     repository.UserRepository
+	apiTokenRepo repository.APITokenRepository
+	now          func() time.Time
+}
+
+func NewAPITokenService(userRepo repository.UserRepository, apiTokenRepo repository.APITokenRepository) APITokenService {
+	return &apiTokenService{userRepo: userRepo, apiTokenRepo: apiTokenRepo, now: time.Now}
+}
+
+// CreateToken issues a token with the requested scopes, which the roles of
+// the user must grant. The token is returned once, only its hash is
+// stored.
+func (s *apiTokenService) CreateToken(userID uuid.UUID, tokenDTO *contract.NewAPITokenDTO) (*domain.APIToken, string, error) {
+	if err := pkg.ValidateStruct(tokenDTO); err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	if tokenDTO.ExpiresAt != nil && !tokenDTO.ExpiresAt.After(now) {
+		return nil, "", pkg.NewInputError("ExpiresAt must be in the future")
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, "", ErrUserNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var scopes []string
+	for _, scope := range tokenDTO.Scopes {
+		if !rolesGrant(userRoles(user), scope) {
+			return nil, "", ErrScopeNotGranted
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	secret, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	secret = domain.APITokenPrefix + secret
+
+	apiToken := &domain.APIToken{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      tokenDTO.Name,
+		TokenHash: pkg.HashSecret(secret),
+		Scopes:    strings.Join(scopes, " "),
+		CreatedAt: now,
+		ExpiresAt: tokenDTO.ExpiresAt,
+	}
+
+	if err := s.apiTokenRepo.Create(apiToken); err != nil {
+		return nil, "", err
+	}
+
+	return apiToken, secret, nil
+}
+
+func (s *apiTokenService) ListTokens(userID uuid.UUID) ([]domain.APIToken, error) {
+	return s.apiTokenRepo.FindActiveByUserID(userID, s.now())
+}
+
+func (s *apiTokenService) RevokeToken(userID, id uuid.UUID) error {
+	revoked, err := s.apiTokenRepo.Revoke(userID, id, s.now())
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrAPITokenNotFound
+	}
+
+	return nil
+}
+
+func (s *apiTokenService) AuthenticateAPIToken(secret string) (*domain.APIToken, []string, error) {
+	if !strings.HasPrefix(secret, domain.APITokenPrefix) {
+		return nil, nil, token.ErrInvalidToken
+	}
+
+	apiToken, err := s.apiTokenRepo.FindByHash(pkg.HashSecret(secret))
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, token.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := s.now()
+	if !apiToken.IsActive(now) {
+		return nil, nil, token.ErrInvalidToken
+	}
+
+	user, err := s.userRepo.FindByID(apiToken.UserID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, token.ErrInvalidToken
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if apiToken.LastUsedAt == nil || now.Sub(*apiToken.LastUsedAt) >= apiTokenTouchInterval {
+		if err := s.apiTokenRepo.Touch(apiToken.ID, now); err != nil {
+			return nil, nil, err
+		}
+		apiToken.LastUsedAt = &now
+	}
+
+	return apiToken, userRoles(user), nil
+}
+
+func rolesGrant(roles []string, permission string) bool {
+	for _, role := range roles {
+		if domain.RoleHasPermission(role, permission) {
+			return true
+		}
+	}
+
+	return false
+}
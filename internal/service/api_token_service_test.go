@@ -0,0 +1,190 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockAPITokenRepository struct {
+	mock.Mock
+}
+
+func (m *mockAPITokenRepository) Create(apiToken *domain.APIToken) error {
+	args := m.Called(apiToken)
+	return args.Error(0)
+}
+
+func (m *mockAPITokenRepository) FindByHash(tokenHash string) (*domain.APIToken, error) {
+	args := m.Called(tokenHash)
+	if apiToken := args.Get(0); apiToken != nil {
+		return apiToken.(*domain.APIToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockAPITokenRepository) FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.APIToken, error) {
+	args := m.Called(userID, now)
+	return args.Get(0).([]domain.APIToken), args.Error(1)
+}
+
+func (m *mockAPITokenRepository) Touch(id uuid.UUID, lastUsedAt time.Time) error {
+	args := m.Called(id, lastUsedAt)
+	return args.Error(0)
+}
+
+func (m *mockAPITokenRepository) Revoke(userID, id uuid.UUID, revokedAt time.Time) (bool, error) {
+	args := m.Called(userID, id, revokedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockAPITokenRepository) RevokeAllByUserID(userID uuid.UUID, revokedAt time.Time) error {
+	args := m.Called(userID, revokedAt)
+	return args.Error(0)
+}
+
+func newTestAPITokenService(now time.Time) (APITokenService, *mockUserRepository, *mockAPITokenRepository) {
+	users := new(mockUserRepository)
+	apiTokens := new(mockAPITokenRepository)
+
+	service := &apiTokenService{userRepo: users, apiTokenRepo: apiTokens, now: func() time.Time { return now }}
+
+	return service, users, apiTokens
+}
+
+func TestAPITokenService_CreateToken(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(90 * 24 * time.Hour)
+	user := newTestUser(t, "supersenha")
+
+	apiTokenService, users, apiTokens := newTestAPITokenService(now)
+	users.On("FindByID", user.ID).Return(user, nil)
+	apiTokens.On("Create", mock.Anything).Return(nil)
+
+	apiToken, secret, err := apiTokenService.CreateToken(user.ID, &contract.NewAPITokenDTO{
+		Name:      "Home Assistant",
+		Scopes:    []string{domain.PermissionReadingsRead, domain.PermissionDevicesWrite, domain.PermissionReadingsRead},
+		ExpiresAt: &expiresAt,
+	})
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, domain.APITokenPrefix))
+	assert.Equal(t, pkg.HashSecret(secret), apiToken.TokenHash)
+	assert.Equal(t, []string{domain.PermissionReadingsRead, domain.PermissionDevicesWrite}, apiToken.ScopeList())
+	assert.Equal(t, user.ID, apiToken.UserID)
+	assert.Equal(t, &expiresAt, apiToken.ExpiresAt)
+	apiTokens.AssertCalled(t, "Create", apiToken)
+}
+
+func TestAPITokenService_CreateToken_Rejected(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	viewer := newTestUser(t, "supersenha")
+	viewer.Role = domain.RoleViewer
+
+	tests := []struct {
+		name string
+		dto  contract.NewAPITokenDTO
+		err  error
+	}{
+		{"no scopes", contract.NewAPITokenDTO{Name: "script"}, nil},
+		{"unknown scope", contract.NewAPITokenDTO{Name: "script", Scopes: []string{domain.PermissionUsersManage}}, nil},
+		{"expired", contract.NewAPITokenDTO{Name: "script", Scopes: []string{domain.PermissionReadingsRead}, ExpiresAt: &past}, nil},
+		{"not granted by role", contract.NewAPITokenDTO{Name: "script", Scopes: []string{domain.PermissionDevicesWrite}}, ErrScopeNotGranted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiTokenService, users, apiTokens := newTestAPITokenService(now)
+			users.On("FindByID", viewer.ID).Return(viewer, nil)
+
+			_, _, err := apiTokenService.CreateToken(viewer.ID, &tt.dto)
+
+			assert.Error(t, err)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+			}
+			apiTokens.AssertNotCalled(t, "Create", mock.Anything)
+		})
+	}
+}
+
+func TestAPITokenService_RevokeToken(t *testing.T) {
+	now := time.Now()
+	userID := uuid.New()
+	id := uuid.New()
+
+	apiTokenService, _, apiTokens := newTestAPITokenService(now)
+	apiTokens.On("Revoke", userID, id, now).Return(true, nil).Once()
+	apiTokens.On("Revoke", userID, id, now).Return(false, nil)
+
+	assert.NoError(t, apiTokenService.RevokeToken(userID, id))
+	assert.ErrorIs(t, apiTokenService.RevokeToken(userID, id), ErrAPITokenNotFound)
+}
+
+func TestAPITokenService_AuthenticateAPIToken(t *testing.T) {
+	now := time.Now()
+	user := newTestUser(t, "supersenha")
+	user.Role = domain.RoleViewer
+	secret := domain.APITokenPrefix + "secret"
+	apiToken := &domain.APIToken{ID: uuid.New(), UserID: user.ID, TokenHash: pkg.HashSecret(secret), Scopes: "readings:read"}
+
+	apiTokenService, users, apiTokens := newTestAPITokenService(now)
+	users.On("FindByID", user.ID).Return(user, nil)
+	apiTokens.On("FindByHash", apiToken.TokenHash).Return(apiToken, nil)
+	apiTokens.On("Touch", apiToken.ID, now).Return(nil)
+
+	authenticated, roles, err := apiTokenService.AuthenticateAPIToken(secret)
+	require.NoError(t, err)
+	assert.Equal(t, apiToken.ID, authenticated.ID)
+	assert.Equal(t, []string{domain.RoleViewer}, roles)
+	assert.Equal(t, &now, authenticated.LastUsedAt)
+
+	// A second call within a minute doesn't write LastUsedAt again.
+	_, _, err = apiTokenService.AuthenticateAPIToken(secret)
+	require.NoError(t, err)
+	apiTokens.AssertNumberOfCalls(t, "Touch", 1)
+}
+
+func TestAPITokenService_AuthenticateAPIToken_Invalid(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	userID := uuid.New()
+
+	tests := []struct {
+		name     string
+		apiToken *domain.APIToken
+	}{
+		{"unknown", nil},
+		{"revoked", &domain.APIToken{ID: uuid.New(), UserID: userID, RevokedAt: &past}},
+		{"expired", &domain.APIToken{ID: uuid.New(), UserID: userID, ExpiresAt: &past}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := domain.APITokenPrefix + "secret"
+
+			apiTokenService, _, apiTokens := newTestAPITokenService(now)
+			if tt.apiToken != nil {
+				apiTokens.On("FindByHash", pkg.HashSecret(secret)).Return(tt.apiToken, nil)
+			} else {
+				apiTokens.On("FindByHash", pkg.HashSecret(secret)).Return(nil, gorm.ErrRecordNotFound)
+			}
+
+			_, _, err := apiTokenService.AuthenticateAPIToken(secret)
+
+			assert.ErrorIs(t, err, token.ErrInvalidToken)
+			apiTokens.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,402 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/mail"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/internal/throttle"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCredentials doesn't tell unknown emails and wrong
+	// passwords apart, so logins can't be used to find registered emails.
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused is returned when a refresh token is presented
+	// after it was already rotated, its whole session is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+	ErrEmailNotVerified   = errors.New("email not verified")
+)
+
+// ClientInfo describes the client a session was started from.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+type AuthConfig struct {
+	RefreshTokenTTL time.Duration
+	// RequireVerifiedEmail refuses logins until the user followed the
+	// verification link sent on sign up.
+	RequireVerifiedEmail bool
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	// AppURL is where links sent by email point to.
+	AppURL string
+	// AccountThrottle and IPThrottle lock out an email or a client IP
+	// after too many failed logins.
+	AccountThrottle throttle.Config
+	IPThrottle      throttle.Config
+	// MFAChallengeTTL is how long a user has to enter their TOTP code after
+	// the password.
+	MFAChallengeTTL time.Duration
+	// TOTPIssuer names the account in authenticator apps.
+	TOTPIssuer string
+	// RecentLoginWindow is how long after signing in a session may make
+	// changes that need a fresh login.
+	RecentLoginWindow time.Duration
+}
+
+func DefaultAuthConfig() AuthConfig {
+	return AuthConfig{
+		RefreshTokenTTL:      30 * 24 * time.Hour,
+		RequireVerifiedEmail: true,
+		EmailVerificationTTL: 24 * time.Hour,
+		PasswordResetTTL:     time.Hour,
+		AppURL:               "http://localhost:3000",
+		AccountThrottle: throttle.Config{
+			MaxFailures: 5,
+			Window:      15 * time.Minute,
+			Lockout:     time.Minute,
+			MaxLockout:  time.Hour,
+		},
+		IPThrottle: throttle.Config{
+			MaxFailures: 50,
+			Window:      15 * time.Minute,
+			Lockout:     5 * time.Minute,
+			MaxLockout:  time.Hour,
+		},
+		MFAChallengeTTL:   5 * time.Minute,
+		TOTPIssuer:        "ThermoSync",
+		RecentLoginWindow: 10 * time.Minute,
+	}
+}
+
+type AuthService interface {
+	// Login returns an MFA challenge instead of tokens when the user has
+	// two-factor authentication enabled.
+	Login(email, password string, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error)
+	VerifyMFA(challengeToken string, code string, client ClientInfo) (*contract.TokenResponseDTO, error)
+	LoginCompleter
+	Refresh(refreshToken string) (*contract.TokenResponseDTO, error)
+	Logout(tokenID string, sessionID uuid.UUID, expiresAt time.Time) error
+	LogoutAll(userID uuid.UUID) error
+	AccountGuard
+	ListSessions(userID uuid.UUID) ([]domain.Session, error)
+	SendVerificationEmail(user *domain.User) error
+	ResendVerificationEmail(email string) error
+	VerifyEmail(verificationToken string) error
+	ForgotPassword(email string) error
+	ResetPassword(resetDTO *contract.ResetPasswordDTO) error
+	EnrollTOTP(userID uuid.UUID) (*contract.TOTPEnrollmentDTO, error)
+	ConfirmTOTP(userID uuid.UUID, code string) (*contract.RecoveryCodesDTO, error)
+	DisableTOTP(userID uuid.UUID, disableDTO *contract.DisableTOTPDTO) error
+}
+
+type authService struct {
+	userRepo          repository.UserRepository
+	sessionRepo       repository.SessionRepository
+	refreshTokenRepo  repository.RefreshTokenRepository
+	passwordResetRepo repository.PasswordResetRepository
+	auditRepo         repository.AuditRepository
+	recoveryCodeRepo  repository.RecoveryCodeRepository
+	apiTokenRepo      repository.APITokenRepository
+	revocations       token.RevocationStore
+	tokens            token.Service
+	mailer            mail.Mailer
+	config            AuthConfig
+	accountThrottle   *throttle.Throttle
+	ipThrottle        *throttle.Throttle
+	now               func() time.Time
+}
+
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	refreshTokenRepo repository.RefreshTokenRepository,
+	passwordResetRepo repository.PasswordResetRepository,
+	auditRepo repository.AuditRepository,
+	recoveryCodeRepo repository.RecoveryCodeRepository,
+	apiTokenRepo repository.APITokenRepository,
+	revocations token.RevocationStore,
+	tokens token.Service,
+	mailer mail.Mailer,
+	config AuthConfig,
+) AuthService {
+	return &authService{
+		userRepo:          userRepo,
+		sessionRepo:       sessionRepo,
+		refreshTokenRepo:  refreshTokenRepo,
+		passwordResetRepo: passwordResetRepo,
+		auditRepo:         auditRepo,
+		recoveryCodeRepo:  recoveryCodeRepo,
+		apiTokenRepo:      apiTokenRepo,
+		revocations:       revocations,
+		tokens:            tokens,
+		mailer:            mailer,
+		config:            config,
+		accountThrottle:   throttle.New(config.AccountThrottle),
+		ipThrottle:        throttle.New(config.IPThrottle),
+		now:               time.Now,
+	}
+}
+
+// Login starts a session for the user with email and password. Failures
+// are counted per account and per client IP, both get locked out for a
+// while after too many of them.
+func (s *authService) Login(email string, password string, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error) {
+	now := s.now()
+
+	if err := s.checkLoginThrottle(email, client, now); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err == gorm.ErrRecordNotFound {
+		compareDummyPassword(password)
+		return nil, nil, s.loginFailed(nil, email, client, now)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		return nil, nil, s.loginFailed(user, email, client, now)
+	}
+
+	s.accountThrottle.Reset(accountThrottleKey(email))
+
+	return s.CompleteLogin(user, client)
+}
+
+// CompleteLogin starts a session for a user who proved who they are, or
+// returns an MFA challenge when they have two-factor authentication.
+func (s *authService) CompleteLogin(user *domain.User, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error) {
+	if s.config.RequireVerifiedEmail && !user.IsEmailVerified() {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	if user.IsMFAEnabled() {
+		challenge, err := s.mfaChallenge(user)
+		return nil, challenge, err
+	}
+
+	tokens, err := s.startSession(user, client)
+	return tokens, nil, err
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token
+// pair. Each refresh token can be used once, using it again revokes the
+// session it belongs to.
+func (s *authService) Refresh(refreshToken string) (*contract.TokenResponseDTO, error) {
+	stored, err := s.refreshTokenRepo.FindByHash(pkg.HashSecret(refreshToken))
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+
+	if !now.Before(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := s.sessionRepo.FindByID(stored.SessionID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedSession(session, now)
+	}
+
+	marked, err := s.refreshTokenRepo.MarkUsed(stored.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, s.revokeReusedSession(session, now)
+	}
+
+	// Reload the user so role changes apply from the next access token.
+	user, err := s.userRepo.FindByID(stored.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if err := s.sessionRepo.Touch(session.ID, now, now.Add(s.config.RefreshTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session.ID)
+}
+
+// Logout revokes an access token and the session it belongs to.
+func (s *authService) Logout(tokenID string, sessionID uuid.UUID, expiresAt time.Time) error {
+	if err := s.revocations.Revoke(tokenID, expiresAt); err != nil {
+		return err
+	}
+
+	if sessionID == uuid.Nil {
+		return nil
+	}
+
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.revokeSession(session, s.now())
+}
+
+// LogoutAll revokes every active session of the user, including the
+// current one, and every personal API token: whoever held the account
+// may have created one to keep access. It backs "sign out everywhere",
+// password resets and the admin routes.
+func (s *authService) LogoutAll(userID uuid.UUID) error {
+	return s.LogoutOthers(userID, uuid.Nil)
+}
+
+// LogoutOthers is LogoutAll sparing the session with keepSessionID, used
+// after a password change.
+func (s *authService) LogoutOthers(userID, keepSessionID uuid.UUID) error {
+	now := s.now()
+
+	if err := s.apiTokenRepo.RevokeAllByUserID(userID, now); err != nil {
+		return err
+	}
+
+	sessions, err := s.sessionRepo.FindActiveByUserID(userID, now)
+	if err != nil {
+		return err
+	}
+
+	for i := range sessions {
+		if sessions[i].ID == keepSessionID {
+			continue
+		}
+		if err := s.revokeSession(&sessions[i], now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *authService) ListSessions(userID uuid.UUID) ([]domain.Session, error) {
+	return s.sessionRepo.FindActiveByUserID(userID, s.now())
+}
+
+func (s *authService) startSession(user *domain.User, client ClientInfo) (*contract.TokenResponseDTO, error) {
+	now := s.now()
+
+	session := &domain.Session{
+		ID:         uuid.New(),
+		UserID:     user.ID,
+		UserAgent:  truncate(client.UserAgent, 255),
+		IPAddress:  client.IPAddress,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.config.RefreshTokenTTL),
+	}
+
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(user, session.ID)
+}
+
+// revokeSession signs the session out: its refresh tokens stop working and
+// its access tokens are rejected until the latest of them has expired.
+func (s *authService) revokeSession(session *domain.Session, now time.Time) error {
+	if err := s.sessionRepo.Revoke(session.ID, now); err != nil {
+		return err
+	}
+
+	return s.revocations.Revoke(session.ID.String(), session.ExpiresAt)
+}
+
+func (s *authService) revokeReusedSession(session *domain.Session, now time.Time) error {
+	if err := s.revokeSession(session, now); err != nil {
+		return err
+	}
+
+	return ErrRefreshTokenReused
+}
+
+func (s *authService) issueTokens(user *domain.User, sessionID uuid.UUID) (*contract.TokenResponseDTO, error) {
+	accessToken, claims, err := s.tokens.Issue(token.Claims{
+		UserID:    user.ID,
+		SessionID: sessionID,
+		Roles:     userRoles(user),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+
+	err = s.refreshTokenRepo.Create(&domain.RefreshToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		SessionID: sessionID,
+		TokenHash: pkg.HashSecret(refreshToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.RefreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &contract.TokenResponseDTO{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(claims.ExpiresAt.Sub(claims.IssuedAt).Seconds()),
+		RefreshToken: refreshToken,
+		Token:        accessToken,
+	}, nil
+}
+
+func userRoles(user *domain.User) []string {
+	if user.Role == "" {
+		return []string{domain.RoleMember}
+	}
+
+	return []string{user.Role}
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+
+	return strings.ToValidUTF8(value[:length], "")
+}
@@ -0,0 +1,415 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/mail"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var testTokens = newTestTokens()
+
+func newTestTokens() token.Service {
+	cfg := token.DefaultConfig()
+	cfg.Keys = []token.Key{{ID: "test", Secret: []byte(strings.Repeat("s", token.MinSecretLength))}}
+
+	tokens, err := token.NewService(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	return tokens
+}
+
+type mockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *mockRefreshTokenRepository) Create(refreshToken *domain.RefreshToken) error {
+	args := m.Called(refreshToken)
+	return args.Error(0)
+}
+
+func (m *mockRefreshTokenRepository) FindByHash(tokenHash string) (*domain.RefreshToken, error) {
+	args := m.Called(tokenHash)
+	if refreshToken := args.Get(0); refreshToken != nil {
+		return refreshToken.(*domain.RefreshToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRefreshTokenRepository) MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error) {
+	args := m.Called(id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+type mockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *mockSessionRepository) Create(session *domain.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *mockSessionRepository) FindByID(id uuid.UUID) (*domain.Session, error) {
+	args := m.Called(id)
+	if session := args.Get(0); session != nil {
+		return session.(*domain.Session), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockSessionRepository) FindActiveByUserID(userID uuid.UUID, now time.Time) ([]domain.Session, error) {
+	args := m.Called(userID, now)
+	return args.Get(0).([]domain.Session), args.Error(1)
+}
+
+func (m *mockSessionRepository) Touch(id uuid.UUID, lastUsedAt time.Time, expiresAt time.Time) error {
+	args := m.Called(id, lastUsedAt, expiresAt)
+	return args.Error(0)
+}
+
+func (m *mockSessionRepository) Revoke(id uuid.UUID, revokedAt time.Time) error {
+	args := m.Called(id, revokedAt)
+	return args.Error(0)
+}
+
+type mockRevocationStore struct {
+	mock.Mock
+}
+
+func (m *mockRevocationStore) Revoke(id string, expiresAt time.Time) error {
+	args := m.Called(id, expiresAt)
+	return args.Error(0)
+}
+
+func (m *mockRevocationStore) IsRevoked(ids ...string) (bool, error) {
+	args := m.Called(ids)
+	return args.Bool(0), args.Error(1)
+}
+
+type mockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *mockAuditRepository) Create(event *domain.AuditEvent) error {
+	args := m.Called(event)
+	return args.Error(0)
+}
+
+type authServiceMocks struct {
+	users          *mockUserRepository
+	sessions       *mockSessionRepository
+	refreshTokens  *mockRefreshTokenRepository
+	passwordResets *mockPasswordResetRepository
+	audit          *mockAuditRepository
+	recoveryCodes  *mockRecoveryCodeRepository
+	apiTokens      *mockAPITokenRepository
+	revocations    *mockRevocationStore
+	mailer         *mail.MemoryMailer
+}
+
+func newTestAuthService() (AuthService, *authServiceMocks) {
+	return newTestAuthServiceWithConfig(DefaultAuthConfig())
+}
+
+func newTestAuthServiceWithConfig(config AuthConfig) (AuthService, *authServiceMocks) {
+	mocks := &authServiceMocks{
+		users:          new(mockUserRepository),
+		sessions:       new(mockSessionRepository),
+		refreshTokens:  new(mockRefreshTokenRepository),
+		passwordResets: new(mockPasswordResetRepository),
+		audit:          new(mockAuditRepository),
+		recoveryCodes:  new(mockRecoveryCodeRepository),
+		apiTokens:      new(mockAPITokenRepository),
+		revocations:    new(mockRevocationStore),
+		mailer:         mail.NewMemoryMailer(),
+	}
+	mocks.apiTokens.On("RevokeAllByUserID", mock.Anything, mock.Anything).Return(nil)
+
+	authService := NewAuthService(
+		mocks.users,
+		mocks.sessions,
+		mocks.refreshTokens,
+		mocks.passwordResets,
+		mocks.audit,
+		mocks.recoveryCodes,
+		mocks.apiTokens,
+		mocks.revocations,
+		testTokens,
+		mocks.mailer,
+		config,
+	)
+
+	return authService, mocks
+}
+
+func newTestUser(t *testing.T, password string) *domain.User {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	verifiedAt := time.Now()
+
+	return &domain.User{
+		ID:              uuid.New(),
+		FirstName:       "Ayrton",
+		Email:           "senna@example.com",
+		Password:        string(hashedPassword),
+		Role:            domain.RoleMember,
+		EmailVerifiedAt: &verifiedAt,
+	}
+}
+
+func TestAuthService_Login_StartsSession(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+
+	var session *domain.Session
+	mocks.sessions.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		session = args.Get(0).(*domain.Session)
+	}).Return(nil)
+
+	var stored *domain.RefreshToken
+	mocks.refreshTokens.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(0).(*domain.RefreshToken)
+	}).Return(nil)
+
+	tokens, _, err := authService.Login(user.Email, "supersenha", ClientInfo{UserAgent: "ThermoSync/2.1 (Android 14)", IPAddress: "203.0.113.7"})
+	require.NoError(t, err)
+
+	claims, err := testTokens.Verify(tokens.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID)
+	assert.Equal(t, session.ID, claims.SessionID)
+	assert.Equal(t, []string{"member"}, claims.Roles)
+	assert.Equal(t, tokens.AccessToken, tokens.Token)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, 900, tokens.ExpiresIn)
+
+	assert.Equal(t, user.ID, session.UserID)
+	assert.Equal(t, "ThermoSync/2.1 (Android 14)", session.UserAgent)
+	assert.Equal(t, "203.0.113.7", session.IPAddress)
+
+	assert.Equal(t, session.ID, stored.SessionID)
+	assert.Equal(t, pkg.HashSecret(tokens.RefreshToken), stored.TokenHash)
+	assert.NotEqual(t, tokens.RefreshToken, stored.TokenHash)
+}
+
+func TestAuthService_Login_InvalidPassword(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+
+	_, _, err := authService.Login(user.Email, "wrong-password", ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_Login_UnknownEmail(t *testing.T) {
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", "nobody@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	_, _, err := authService.Login("nobody@example.com", "supersenha", ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_Login_UnverifiedEmail(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	user.EmailVerifiedAt = nil
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+
+	_, _, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrEmailNotVerified)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_Refresh_RotatesToken(t *testing.T) {
+	userID := uuid.New()
+	session := &domain.Session{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}
+	current := &domain.RefreshToken{ID: uuid.New(), UserID: userID, SessionID: session.ID, ExpiresAt: time.Now().Add(time.Hour)}
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByID", userID).Return(&domain.User{ID: userID, Role: domain.RoleAdmin}, nil)
+	mocks.sessions.On("FindByID", session.ID).Return(session, nil)
+	mocks.sessions.On("Touch", session.ID, mock.Anything, mock.Anything).Return(nil)
+	mocks.refreshTokens.On("FindByHash", pkg.HashSecret("current")).Return(current, nil)
+	mocks.refreshTokens.On("MarkUsed", current.ID, mock.Anything).Return(true, nil)
+
+	var rotated *domain.RefreshToken
+	mocks.refreshTokens.On("Create", mock.Anything).Run(func(args mock.Arguments) {
+		rotated = args.Get(0).(*domain.RefreshToken)
+	}).Return(nil)
+
+	tokens, err := authService.Refresh("current")
+	require.NoError(t, err)
+
+	assert.NotEqual(t, "current", tokens.RefreshToken)
+	assert.Equal(t, session.ID, rotated.SessionID)
+
+	claims, err := testTokens.Verify(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	assert.Equal(t, pkg.HashSecret(tokens.RefreshToken), rotated.TokenHash)
+	mocks.sessions.AssertNumberOfCalls(t, "Revoke", 0)
+}
+
+func TestAuthService_Refresh_ReuseRevokesSession(t *testing.T) {
+	usedAt := time.Now().Add(-time.Minute)
+	session := &domain.Session{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	used := &domain.RefreshToken{ID: uuid.New(), UserID: uuid.New(), SessionID: session.ID, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}
+
+	authService, mocks := newTestAuthService()
+	mocks.refreshTokens.On("FindByHash", pkg.HashSecret("used")).Return(used, nil)
+	mocks.sessions.On("FindByID", session.ID).Return(session, nil)
+	mocks.sessions.On("Revoke", session.ID, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", session.ID.String(), session.ExpiresAt).Return(nil)
+
+	_, err := authService.Refresh("used")
+
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	mocks.sessions.AssertNumberOfCalls(t, "Revoke", 1)
+	mocks.revocations.AssertNumberOfCalls(t, "Revoke", 1)
+	mocks.refreshTokens.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_Refresh_ConcurrentUseRevokesSession(t *testing.T) {
+	session := &domain.Session{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	current := &domain.RefreshToken{ID: uuid.New(), UserID: uuid.New(), SessionID: session.ID, ExpiresAt: time.Now().Add(time.Hour)}
+
+	authService, mocks := newTestAuthService()
+	mocks.refreshTokens.On("FindByHash", pkg.HashSecret("current")).Return(current, nil)
+	mocks.refreshTokens.On("MarkUsed", current.ID, mock.Anything).Return(false, nil)
+	mocks.sessions.On("FindByID", session.ID).Return(session, nil)
+	mocks.sessions.On("Revoke", session.ID, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", session.ID.String(), session.ExpiresAt).Return(nil)
+
+	_, err := authService.Refresh("current")
+
+	assert.ErrorIs(t, err, ErrRefreshTokenReused)
+	mocks.sessions.AssertNumberOfCalls(t, "Revoke", 1)
+}
+
+func TestAuthService_Refresh_InvalidTokens(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+	active := &domain.Session{ID: uuid.New()}
+	revoked := &domain.Session{ID: uuid.New(), RevokedAt: &revokedAt}
+
+	tests := []struct {
+		name   string
+		stored *domain.RefreshToken
+		err    error
+	}{
+		{"unknown", nil, gorm.ErrRecordNotFound},
+		{"expired", &domain.RefreshToken{ID: uuid.New(), SessionID: active.ID, ExpiresAt: time.Now().Add(-time.Second)}, nil},
+		{"revoked session", &domain.RefreshToken{ID: uuid.New(), SessionID: revoked.ID, ExpiresAt: time.Now().Add(time.Hour)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newTestAuthService()
+			mocks.refreshTokens.On("FindByHash", mock.Anything).Return(tt.stored, tt.err)
+			mocks.sessions.On("FindByID", active.ID).Return(active, nil)
+			mocks.sessions.On("FindByID", revoked.ID).Return(revoked, nil)
+
+			_, err := authService.Refresh("token")
+
+			assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+			mocks.refreshTokens.AssertNumberOfCalls(t, "MarkUsed", 0)
+		})
+	}
+}
+
+func TestAuthService_Logout_RevokesTokenAndSession(t *testing.T) {
+	session := &domain.Session{ID: uuid.New(), ExpiresAt: time.Now().Add(time.Hour)}
+	claims := &token.Claims{ID: uuid.NewString(), UserID: uuid.New(), SessionID: session.ID, ExpiresAt: time.Now().Add(time.Minute)}
+
+	authService, mocks := newTestAuthService()
+	mocks.sessions.On("FindByID", session.ID).Return(session, nil)
+	mocks.sessions.On("Revoke", session.ID, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", claims.ID, claims.ExpiresAt).Return(nil)
+	mocks.revocations.On("Revoke", session.ID.String(), session.ExpiresAt).Return(nil)
+
+	err := authService.Logout(claims.ID, claims.SessionID, claims.ExpiresAt)
+
+	assert.NoError(t, err)
+	mocks.sessions.AssertNumberOfCalls(t, "Revoke", 1)
+	mocks.revocations.AssertNumberOfCalls(t, "Revoke", 2)
+}
+
+func TestAuthService_LogoutAll_RevokesEverySession(t *testing.T) {
+	userID := uuid.New()
+	sessions := []domain.Session{
+		{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)},
+		{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(2 * time.Hour)},
+	}
+
+	authService, mocks := newTestAuthService()
+	mocks.sessions.On("FindActiveByUserID", userID, mock.Anything).Return(sessions, nil)
+	mocks.sessions.On("Revoke", mock.Anything, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", sessions[0].ID.String(), sessions[0].ExpiresAt).Return(nil)
+	mocks.revocations.On("Revoke", sessions[1].ID.String(), sessions[1].ExpiresAt).Return(nil)
+
+	err := authService.LogoutAll(userID)
+
+	assert.NoError(t, err)
+	mocks.sessions.AssertNumberOfCalls(t, "Revoke", 2)
+	mocks.revocations.AssertExpectations(t)
+	mocks.apiTokens.AssertCalled(t, "RevokeAllByUserID", userID, mock.Anything)
+}
+
+func TestAuthService_LogoutOthers_KeepsGivenSession(t *testing.T) {
+	userID := uuid.New()
+	current := domain.Session{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}
+	other := domain.Session{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}
+
+	authService, mocks := newTestAuthService()
+	mocks.sessions.On("FindActiveByUserID", userID, mock.Anything).Return([]domain.Session{current, other}, nil)
+	mocks.sessions.On("Revoke", other.ID, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", other.ID.String(), other.ExpiresAt).Return(nil)
+
+	err := authService.LogoutOthers(userID, current.ID)
+
+	assert.NoError(t, err)
+	mocks.sessions.AssertNotCalled(t, "Revoke", current.ID, mock.Anything)
+	mocks.sessions.AssertCalled(t, "Revoke", other.ID, mock.Anything)
+	mocks.apiTokens.AssertCalled(t, "RevokeAllByUserID", userID, mock.Anything)
+}
+
+func TestAuthService_ConfirmRecentLogin(t *testing.T) {
+	user, code := newMFAUser(t)
+	recent := &domain.Session{ID: uuid.New(), UserID: user.ID, CreatedAt: time.Now().Add(-time.Minute)}
+	old := &domain.Session{ID: uuid.New(), UserID: user.ID, CreatedAt: time.Now().Add(-time.Hour)}
+	foreign := &domain.Session{ID: uuid.New(), UserID: uuid.New(), CreatedAt: time.Now()}
+
+	authService, mocks := newTestAuthService()
+	mocks.sessions.On("FindByID", recent.ID).Return(recent, nil)
+	mocks.sessions.On("FindByID", old.ID).Return(old, nil)
+	mocks.sessions.On("FindByID", foreign.ID).Return(foreign, nil)
+	mocks.users.On("UseTOTPStep", user.ID, mock.Anything).Return(true, nil)
+
+	assert.NoError(t, authService.ConfirmRecentLogin(user, recent.ID, ""))
+	assert.ErrorIs(t, authService.ConfirmRecentLogin(user, old.ID, ""), ErrReauthenticationRequired)
+	assert.ErrorIs(t, authService.ConfirmRecentLogin(user, foreign.ID, ""), ErrReauthenticationRequired)
+	assert.ErrorIs(t, authService.ConfirmRecentLogin(user, old.ID, "000000"), ErrInvalidMFACode)
+	assert.NoError(t, authService.ConfirmRecentLogin(user, old.ID, code))
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceNotFound           = errors.New("device not found")
+	ErrDeviceRetired            = errors.New("device is retired")
+	ErrInvalidDeviceCredentials = errors.New("invalid device credentials")
+)
+
+type DeviceService interface {
+	CreateDevice(userID uuid.UUID, deviceDTO *contract.NewDeviceDTO) (*domain.Device, string, error)
+	ListDevices(userID uuid.UUID) ([]domain.Device, error)
+	FindDevice(userID, deviceID uuid.UUID) (*domain.Device, error)
+	UpdateDevice(userID, deviceID uuid.UUID, deviceDTO *contract.UpdateDeviceDTO) (*domain.Device, error)
+	RetireDevice(userID, deviceID uuid.UUID) error
+	RotateDeviceSecret(userID, deviceID uuid.UUID) (*domain.Device, string, error)
+	AuthenticateDevice(deviceID uuid.UUID, secret string) (*domain.Device, error)
+}
+
+// DeviceDisconnector closes the live connection of a device, if it has
+// one. The websocket hub implements it.
+type DeviceDisconnector interface {
+	DisconnectDevice(deviceID uuid.UUID)
+}
+
+type deviceService struct {
+	deviceRepo  repository.DeviceRepository
+	homeRepo    repository.HomeRepository
+	connections DeviceDisconnector
+}
+
+func NewDeviceService(repo repository.DeviceRepository, homeRepo repository.HomeRepository, connections DeviceDisconnector) DeviceService {
+	return &deviceService{deviceRepo: repo, homeRepo: homeRepo, connections: connections}
+}
+
+// CreateDevice registers a device and returns it together with the secret
+// the device must present when connecting, only its hash is stored.
+func (s *deviceService) CreateDevice(userID uuid.UUID, deviceDTO *contract.NewDeviceDTO) (*domain.Device, string, error) {
+	if err := pkg.ValidateStruct(deviceDTO); err != nil {
+		return nil, "", err
+	}
+
+	device, err := s.deviceRepo.FindActiveBySerial(deviceDTO.Serial)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, "", err
+	}
+	if device != nil {
+		return nil, "", pkg.NewInputError("serial already registred")
+	}
+
+	secret, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	temperatureUnit := deviceDTO.TemperatureUnit
+	if temperatureUnit == "" {
+		temperatureUnit = domain.UnitCelsius
+	}
+
+	device = &domain.Device{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Name:            deviceDTO.Name,
+		Room:            deviceDTO.Room,
+		Serial:          deviceDTO.Serial,
+		SecretHash:      pkg.HashSecret(secret),
+		TemperatureUnit: temperatureUnit,
+	}
+
+	if err := s.deviceRepo.Create(device); err != nil {
+		return nil, "", err
+	}
+
+	return device, secret, nil
+}
+
+func (s *deviceService) ListDevices(userID uuid.UUID) ([]domain.Device, error) {
+	return s.deviceRepo.FindActiveByUserID(userID)
+}
+
+// FindDevice returns the device when it belongs to the given user or is in
+// a home shared with them, other devices are reported as not found.
+func (s *deviceService) FindDevice(userID, deviceID uuid.UUID) (*domain.Device, error) {
+	return s.findDevice(userID, deviceID, domain.PermissionDevicesRead)
+}
+
+// findDevice returns the device when the user owns it, or when their role
+// in the home it is in grants permission.
+func (s *deviceService) findDevice(userID, deviceID uuid.UUID, permission string) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if device.UserID == userID {
+		return device, nil
+	}
+	if device.RoomID == nil {
+		return nil, ErrDeviceNotFound
+	}
+
+	room, err := s.homeRepo.FindRoomByID(*device.RoomID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkHomeRole(s.homeRepo, userID, room.HomeID, room.UserID, permission)
+	if err == ErrHomeNotFound {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+func (s *deviceService) UpdateDevice(userID, deviceID uuid.UUID, deviceDTO *contract.UpdateDeviceDTO) (*domain.Device, error) {
+	if err := pkg.ValidateStruct(deviceDTO); err != nil {
+		return nil, err
+	}
+
+	device, err := s.findDevice(userID, deviceID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return nil, err
+	}
+	if device.RetiredAt != nil {
+		return nil, ErrDeviceRetired
+	}
+
+	if deviceDTO.Name != nil {
+		device.Name = *deviceDTO.Name
+	}
+	if deviceDTO.Room != nil {
+		device.Room = *deviceDTO.Room
+	}
+	if deviceDTO.TemperatureUnit != nil {
+		device.TemperatureUnit = *deviceDTO.TemperatureUnit
+	}
+
+	if err := s.deviceRepo.Update(device); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// RetireDevice takes a device out of service. The row is kept so its
+// readings history stays queryable, but it no longer accepts readings.
+func (s *deviceService) RetireDevice(userID, deviceID uuid.UUID) error {
+	device, err := s.findDevice(userID, deviceID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return err
+	}
+	if device.RetiredAt != nil {
+		return nil
+	}
+
+	retiredAt := time.Now().UTC()
+	device.RetiredAt = &retiredAt
+
+	if err := s.deviceRepo.Update(device); err != nil {
+		return err
+	}
+
+	s.connections.DisconnectDevice(device.ID)
+
+	return nil
+}
+
+// RotateDeviceSecret issues a new secret for the device, the previous one
+// stops working immediately and a connection made with it is closed.
+func (s *deviceService) RotateDeviceSecret(userID, deviceID uuid.UUID) (*domain.Device, string, error) {
+	device, err := s.findDevice(userID, deviceID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return nil, "", err
+	}
+	if device.RetiredAt != nil {
+		return nil, "", ErrDeviceRetired
+	}
+
+	secret, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	device.SecretHash = pkg.HashSecret(secret)
+
+	if err := s.deviceRepo.Update(device); err != nil {
+		return nil, "", err
+	}
+
+	s.connections.DisconnectDevice(device.ID)
+
+	return device, secret, nil
+}
+
+func (s *deviceService) AuthenticateDevice(deviceID uuid.UUID, secret string) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidDeviceCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if device.RetiredAt != nil || device.SecretHash == "" || !pkg.CompareSecret(device.SecretHash, secret) {
+		return nil, ErrInvalidDeviceCredentials
+	}
+
+	return device, nil
+}
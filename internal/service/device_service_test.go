@@ -0,0 +1,288 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+type mockDeviceRepository struct {
+	mock.Mock
+}
+
+func (m *mockDeviceRepository) Create(device *domain.Device) error {
+	args := m.Called(device)
+	return args.Error(0)
+}
+
+func (m *mockDeviceRepository) Update(device *domain.Device) error {
+	args := m.Called(device)
+	return args.Error(0)
+}
+
+func (m *mockDeviceRepository) FindByID(id uuid.UUID) (*domain.Device, error) {
+	args := m.Called(id)
+	if device := args.Get(0); device != nil {
+		return device.(*domain.Device), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeviceRepository) FindActiveBySerial(serial string) (*domain.Device, error) {
+	args := m.Called(serial)
+	if device := args.Get(0); device != nil {
+		return device.(*domain.Device), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeviceRepository) FindActiveByUserID(userID uuid.UUID) ([]domain.Device, error) {
+	args := m.Called(userID)
+	if devices := args.Get(0); devices != nil {
+		return devices.([]domain.Device), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeviceRepository) FindByRoomIDs(roomIDs []uuid.UUID) ([]domain.Device, error) {
+	args := m.Called(roomIDs)
+	if devices := args.Get(0); devices != nil {
+		return devices.([]domain.Device), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockDeviceRepository) UpdateLastSeen(id uuid.UUID, lastSeenAt time.Time) error {
+	args := m.Called(id, lastSeenAt)
+	return args.Error(0)
+}
+
+type mockDeviceDisconnector struct {
+	mock.Mock
+}
+
+func (m *mockDeviceDisconnector) DisconnectDevice(deviceID uuid.UUID) {
+	m.Called(deviceID)
+}
+
+func TestDeviceService_CreateDevice_Success(t *testing.T) {
+	userID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindActiveBySerial", "TS-0001").Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	device, secret, err := deviceService.CreateDevice(userID, &contract.NewDeviceDTO{
+		Name:   "Living room sensor",
+		Room:   "Living room",
+		Serial: "TS-0001",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, device.UserID)
+	assert.Equal(t, "TS-0001", device.Serial)
+	assert.NotEmpty(t, secret)
+	assert.NotEqual(t, secret, device.SecretHash)
+	assert.True(t, pkg.CompareSecret(device.SecretHash, secret))
+
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestDeviceService_CreateDevice_SerialAlreadyRegistred(t *testing.T) {
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindActiveBySerial", "TS-0001").Return(&domain.Device{ID: uuid.New()}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	_, _, err := deviceService.CreateDevice(uuid.New(), &contract.NewDeviceDTO{
+		Name:   "Living room sensor",
+		Serial: "TS-0001",
+	})
+
+	assert.Equal(t, "serial already registred", err.Error())
+	mockRepo.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestDeviceService_CreateDevice_NameIsRequired(t *testing.T) {
+	mockRepo := new(mockDeviceRepository)
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	_, _, err := deviceService.CreateDevice(uuid.New(), &contract.NewDeviceDTO{
+		Serial: "TS-0001",
+	})
+
+	assert.Equal(t, "Name is required", err.Error())
+}
+
+func TestDeviceService_FindDevice_OtherOwner(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: uuid.New()}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	device, err := deviceService.FindDevice(uuid.New(), deviceID)
+
+	assert.Nil(t, device)
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+}
+
+func TestDeviceService_FindDevice_SharedThroughHome(t *testing.T) {
+	room := &domain.Room{ID: uuid.New(), HomeID: uuid.New(), UserID: uuid.New()}
+	device := &domain.Device{ID: uuid.New(), UserID: room.UserID, RoomID: &room.ID}
+	viewerID := uuid.New()
+	strangerID := uuid.New()
+	name := "Bedroom sensor"
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", device.ID).Return(device, nil)
+
+	mockHomeRepo := new(mockHomeRepository)
+	mockHomeRepo.On("FindRoomByID", room.ID).Return(room, nil)
+	mockHomeRepo.On("FindMember", room.HomeID, viewerID).Return(&domain.HomeMember{HomeID: room.HomeID, UserID: viewerID, Role: domain.RoleViewer}, nil)
+	mockHomeRepo.On("FindMember", room.HomeID, strangerID).Return(nil, gorm.ErrRecordNotFound)
+
+	deviceService := NewDeviceService(mockRepo, mockHomeRepo, new(mockDeviceDisconnector))
+
+	found, err := deviceService.FindDevice(viewerID, device.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, device, found)
+
+	_, err = deviceService.UpdateDevice(viewerID, device.ID, &contract.UpdateDeviceDTO{Name: &name})
+	assert.ErrorIs(t, err, ErrHomeAccessDenied)
+
+	_, err = deviceService.FindDevice(strangerID, device.ID)
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestDeviceService_UpdateDevice_Rename(t *testing.T) {
+	userID := uuid.New()
+	deviceID := uuid.New()
+	name := "Bedroom sensor"
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID, Name: "Old", Room: "Bedroom"}, nil)
+	mockRepo.On("Update", mock.Anything).Return(nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	device, err := deviceService.UpdateDevice(userID, deviceID, &contract.UpdateDeviceDTO{Name: &name})
+
+	assert.NoError(t, err)
+	assert.Equal(t, name, device.Name)
+	assert.Equal(t, "Bedroom", device.Room)
+}
+
+func TestDeviceService_UpdateDevice_Retired(t *testing.T) {
+	userID := uuid.New()
+	deviceID := uuid.New()
+	retiredAt := time.Now()
+	name := "Bedroom sensor"
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID, RetiredAt: &retiredAt}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	_, err := deviceService.UpdateDevice(userID, deviceID, &contract.UpdateDeviceDTO{Name: &name})
+
+	assert.ErrorIs(t, err, ErrDeviceRetired)
+	mockRepo.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func TestDeviceService_RetireDevice_Success(t *testing.T) {
+	userID := uuid.New()
+	deviceID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID}, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(device *domain.Device) bool {
+		return device.RetiredAt != nil
+	})).Return(nil)
+
+	connections := new(mockDeviceDisconnector)
+	connections.On("DisconnectDevice", deviceID).Return()
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), connections)
+
+	err := deviceService.RetireDevice(userID, deviceID)
+
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "Update", 1)
+	connections.AssertCalled(t, "DisconnectDevice", deviceID)
+}
+
+func TestDeviceService_RotateDeviceSecret_Success(t *testing.T) {
+	userID := uuid.New()
+	deviceID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID, SecretHash: pkg.HashSecret("old")}, nil)
+	mockRepo.On("Update", mock.Anything).Return(nil)
+
+	connections := new(mockDeviceDisconnector)
+	connections.On("DisconnectDevice", deviceID).Return()
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), connections)
+
+	device, secret, err := deviceService.RotateDeviceSecret(userID, deviceID)
+
+	assert.NoError(t, err)
+	assert.True(t, pkg.CompareSecret(device.SecretHash, secret))
+	assert.False(t, pkg.CompareSecret(device.SecretHash, "old"))
+	connections.AssertCalled(t, "DisconnectDevice", deviceID)
+}
+
+func TestDeviceService_AuthenticateDevice_Success(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, SecretHash: pkg.HashSecret("s3cret")}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	device, err := deviceService.AuthenticateDevice(deviceID, "s3cret")
+
+	assert.NoError(t, err)
+	assert.Equal(t, deviceID, device.ID)
+}
+
+func TestDeviceService_AuthenticateDevice_WrongSecret(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, SecretHash: pkg.HashSecret("s3cret")}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	device, err := deviceService.AuthenticateDevice(deviceID, "guess")
+
+	assert.Nil(t, device)
+	assert.ErrorIs(t, err, ErrInvalidDeviceCredentials)
+}
+
+func TestDeviceService_AuthenticateDevice_RetiredDevice(t *testing.T) {
+	deviceID := uuid.New()
+	retiredAt := time.Now()
+
+	mockRepo := new(mockDeviceRepository)
+	mockRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, SecretHash: pkg.HashSecret("s3cret"), RetiredAt: &retiredAt}, nil)
+
+	deviceService := NewDeviceService(mockRepo, new(mockHomeRepository), new(mockDeviceDisconnector))
+
+	_, err := deviceService.AuthenticateDevice(deviceID, "s3cret")
+
+	assert.ErrorIs(t, err, ErrInvalidDeviceCredentials)
+}
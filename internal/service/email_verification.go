@@ -0,0 +1,93 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/mail"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"gorm.io/gorm"
+)
+
+const emailVerificationPurpose = "email-verification"
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+// EmailVerifier sends the verification email of a new user.
+type EmailVerifier interface {
+	SendVerificationEmail(user *domain.User) error
+}
+
+func (s *authService) SendVerificationEmail(user *domain.User) error {
+	verificationToken, _, err := s.tokens.IssueFor(emailVerificationPurpose, token.Claims{UserID: user.ID}, s.config.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.config.AppURL + "/verify-email?token=" + url.QueryEscape(verificationToken)
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Verify your ThermoSync email",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s. If you didn't create a ThermoSync account you can ignore this email.\n",
+			user.FirstName, link, s.config.EmailVerificationTTL),
+	})
+}
+
+// ResendVerificationEmail sends a new link to email if it belongs to an
+// unverified user. It doesn't report whether it did, so it can't be used
+// to find out which emails are registered.
+func (s *authService) ResendVerificationEmail(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+// VerifyEmail marks the user of verificationToken verified, each token
+// works once.
+func (s *authService) VerifyEmail(verificationToken string) error {
+	claims, err := s.tokens.VerifyFor(emailVerificationPurpose, verificationToken)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+
+	used, err := s.revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return err
+	}
+	if used {
+		return ErrInvalidVerificationToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err == gorm.ErrRecordNotFound {
+		return ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return err
+	}
+
+	if !user.IsEmailVerified() {
+		now := s.now()
+		user.EmailVerifiedAt = &now
+
+		if err := s.userRepo.Update(user); err != nil {
+			return err
+		}
+	}
+
+	return s.revocations.Revoke(claims.ID, claims.ExpiresAt)
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+var verificationLink = regexp.MustCompile(`/verify-email\?token=(\S+)`)
+
+func sentVerificationToken(t *testing.T, mocks *authServiceMocks) string {
+	messages := mocks.mailer.Messages()
+	require.Len(t, messages, 1)
+
+	match := verificationLink.FindStringSubmatch(messages[0].Body)
+	require.NotNil(t, match)
+
+	verificationToken, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+
+	return verificationToken
+}
+
+func TestAuthService_SendVerificationEmail(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	user.EmailVerifiedAt = nil
+
+	authService, mocks := newTestAuthService()
+
+	require.NoError(t, authService.SendVerificationEmail(user))
+
+	messages := mocks.mailer.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, "senna@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Body, "http://localhost:3000/verify-email?token=")
+
+	claims, err := testTokens.VerifyFor(emailVerificationPurpose, sentVerificationToken(t, mocks))
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID)
+}
+
+func TestAuthService_VerifyEmail_Success(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	user.EmailVerifiedAt = nil
+
+	authService, mocks := newTestAuthService()
+	require.NoError(t, authService.SendVerificationEmail(user))
+	verificationToken := sentVerificationToken(t, mocks)
+
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("Update", mock.Anything).Return(nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+	mocks.revocations.On("Revoke", mock.Anything, mock.Anything).Return(nil)
+
+	err := authService.VerifyEmail(verificationToken)
+
+	assert.NoError(t, err)
+	assert.True(t, user.IsEmailVerified())
+	mocks.users.AssertNumberOfCalls(t, "Update", 1)
+	mocks.revocations.AssertNumberOfCalls(t, "Revoke", 1)
+}
+
+func TestAuthService_VerifyEmail_TokenIsSingleUse(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	user.EmailVerifiedAt = nil
+
+	authService, mocks := newTestAuthService()
+	require.NoError(t, authService.SendVerificationEmail(user))
+	verificationToken := sentVerificationToken(t, mocks)
+
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(true, nil)
+
+	err := authService.VerifyEmail(verificationToken)
+
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	mocks.users.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func TestAuthService_VerifyEmail_RejectsAccessToken(t *testing.T) {
+	accessToken, _, err := testTokens.Issue(token.Claims{UserID: newTestUser(t, "supersenha").ID})
+	require.NoError(t, err)
+
+	authService, mocks := newTestAuthService()
+
+	err = authService.VerifyEmail(accessToken)
+
+	assert.ErrorIs(t, err, ErrInvalidVerificationToken)
+	mocks.users.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func TestAuthService_ResendVerificationEmail(t *testing.T) {
+	verified := newTestUser(t, "supersenha")
+	unverified := &domain.User{ID: verified.ID, Email: "prost@example.com"}
+
+	tests := []struct {
+		name  string
+		email string
+		user  *domain.User
+		err   error
+		sent  int
+	}{
+		{"unverified", "prost@example.com", unverified, nil, 1},
+		{"already verified", "senna@example.com", verified, nil, 0},
+		{"unknown email", "nobody@example.com", nil, gorm.ErrRecordNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newTestAuthService()
+			mocks.users.On("FindByEmail", tt.email).Return(tt.user, tt.err)
+
+			err := authService.ResendVerificationEmail(tt.email)
+
+			assert.NoError(t, err)
+			assert.Len(t, mocks.mailer.Messages(), tt.sent)
+		})
+	}
+}
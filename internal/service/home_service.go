@@ -0,0 +1,422 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrHomeNotFound       = errors.New("home not found")
+	ErrRoomNotFound       = errors.New("room not found")
+	ErrHomeMemberNotFound = errors.New("home member not found")
+	// ErrHomeAccessDenied is returned to members of a home whose role in it
+	// doesn't allow what they asked for.
+	ErrHomeAccessDenied = errors.New("your role in this home doesn't allow this")
+)
+
+// HomeService manages homes, their rooms, which devices are in each room
+// and who a home is shared with. The owner of a home is its admin, other
+// users see it once added as members: viewers only look, members also
+// manage rooms and devices, admins also rename or delete the home and
+// choose who it is shared with. Homes and rooms not shared with the user
+// are reported as not found.
+type HomeService interface {
+	CreateHome(userID uuid.UUID, homeDTO *contract.NewHomeDTO) (*domain.Home, error)
+	ListHomes(userID uuid.UUID) ([]domain.Home, error)
+	FindHome(userID, homeID uuid.UUID) (*domain.Home, error)
+	UpdateHome(userID, homeID uuid.UUID, homeDTO *contract.UpdateHomeDTO) (*domain.Home, error)
+	DeleteHome(userID, homeID uuid.UUID) error
+
+	CreateRoom(userID, homeID uuid.UUID, roomDTO *contract.NewRoomDTO) (*domain.Room, error)
+	ListRooms(userID, homeID uuid.UUID) ([]domain.Room, error)
+	FindRoom(userID, roomID uuid.UUID) (*domain.Room, error)
+	UpdateRoom(userID, roomID uuid.UUID, roomDTO *contract.UpdateRoomDTO) (*domain.Room, error)
+	DeleteRoom(userID, roomID uuid.UUID) error
+
+	ListRoomDevices(userID, roomID uuid.UUID) ([]domain.Device, error)
+	// ListHomeDevices returns the devices in every room of the home.
+	ListHomeDevices(userID, homeID uuid.UUID) ([]domain.Device, error)
+	// AssignDevice moves the device into the room, out of any room it was
+	// in before.
+	AssignDevice(userID, roomID, deviceID uuid.UUID) (*domain.Device, error)
+	UnassignDevice(userID, roomID, deviceID uuid.UUID) error
+
+	ListMembers(userID, homeID uuid.UUID) ([]domain.HomeMember, error)
+	// SetMember shares the home with another user or changes their role
+	// in it, only admins of the home may.
+	SetMember(userID, homeID uuid.UUID, memberDTO *contract.SetHomeMemberDTO) (*domain.HomeMember, error)
+	// RemoveMember takes a member out of the home, admins may remove
+	// anyone and members may leave.
+	RemoveMember(userID, homeID, memberID uuid.UUID) error
+}
+
+type homeService struct {
+	homeRepo   repository.HomeRepository
+	deviceRepo repository.DeviceRepository
+	userRepo   repository.UserRepository
+}
+
+func NewHomeService(homeRepo repository.HomeRepository, deviceRepo repository.DeviceRepository, userRepo repository.UserRepository) HomeService {
+	return &homeService{homeRepo: homeRepo, deviceRepo: deviceRepo, userRepo: userRepo}
+}
+
+func (s *homeService) CreateHome(userID uuid.UUID, homeDTO *contract.NewHomeDTO) (*domain.Home, error) {
+	if err := pkg.ValidateStruct(homeDTO); err != nil {
+		return nil, err
+	}
+
+	home := &domain.Home{
+		ID:     uuid.New(),
+		UserID: userID,
+		Name:   homeDTO.Name,
+	}
+
+	if err := s.homeRepo.CreateHome(home); err != nil {
+		return nil, err
+	}
+
+	return home, nil
+}
+
+func (s *homeService) ListHomes(userID uuid.UUID) ([]domain.Home, error) {
+	return s.homeRepo.FindHomesByUserID(userID)
+}
+
+func (s *homeService) FindHome(userID, homeID uuid.UUID) (*domain.Home, error) {
+	return s.findHome(userID, homeID, domain.PermissionDevicesRead)
+}
+
+// findHome returns the home when the user's role in it grants permission.
+func (s *homeService) findHome(userID, homeID uuid.UUID, permission string) (*domain.Home, error) {
+	home, err := s.homeRepo.FindHomeByID(homeID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrHomeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkHomeRole(s.homeRepo, userID, home.ID, home.UserID, permission); err != nil {
+		return nil, err
+	}
+
+	return home, nil
+}
+
+func (s *homeService) UpdateHome(userID, homeID uuid.UUID, homeDTO *contract.UpdateHomeDTO) (*domain.Home, error) {
+	if err := pkg.ValidateStruct(homeDTO); err != nil {
+		return nil, err
+	}
+
+	home, err := s.findHome(userID, homeID, domain.PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if homeDTO.Name != nil {
+		home.Name = *homeDTO.Name
+	}
+
+	if err := s.homeRepo.UpdateHome(home); err != nil {
+		return nil, err
+	}
+
+	return home, nil
+}
+
+// DeleteHome removes the home and its rooms, the devices in them are kept
+// without a room.
+func (s *homeService) DeleteHome(userID, homeID uuid.UUID) error {
+	if _, err := s.findHome(userID, homeID, domain.PermissionUsersManage); err != nil {
+		return err
+	}
+
+	return s.homeRepo.DeleteHome(homeID)
+}
+
+func (s *homeService) CreateRoom(userID, homeID uuid.UUID, roomDTO *contract.NewRoomDTO) (*domain.Room, error) {
+	if err := pkg.ValidateStruct(roomDTO); err != nil {
+		return nil, err
+	}
+
+	home, err := s.findHome(userID, homeID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	room := &domain.Room{
+		ID:     uuid.New(),
+		HomeID: home.ID,
+		UserID: home.UserID,
+		Name:   roomDTO.Name,
+	}
+
+	if err := s.homeRepo.CreateRoom(room); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (s *homeService) ListRooms(userID, homeID uuid.UUID) ([]domain.Room, error) {
+	if _, err := s.FindHome(userID, homeID); err != nil {
+		return nil, err
+	}
+
+	return s.homeRepo.FindRoomsByHomeID(homeID)
+}
+
+func (s *homeService) FindRoom(userID, roomID uuid.UUID) (*domain.Room, error) {
+	return s.findRoom(userID, roomID, domain.PermissionDevicesRead)
+}
+
+// findRoom returns the room when the user's role in its home grants
+// permission.
+func (s *homeService) findRoom(userID, roomID uuid.UUID, permission string) (*domain.Room, error) {
+	room, err := s.homeRepo.FindRoomByID(roomID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkHomeRole(s.homeRepo, userID, room.HomeID, room.UserID, permission)
+	if err == ErrHomeNotFound {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (s *homeService) UpdateRoom(userID, roomID uuid.UUID, roomDTO *contract.UpdateRoomDTO) (*domain.Room, error) {
+	if err := pkg.ValidateStruct(roomDTO); err != nil {
+		return nil, err
+	}
+
+	room, err := s.findRoom(userID, roomID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if roomDTO.Name != nil {
+		room.Name = *roomDTO.Name
+	}
+
+	if err := s.homeRepo.UpdateRoom(room); err != nil {
+		return nil, err
+	}
+
+	return room, nil
+}
+
+// DeleteRoom removes the room, its devices are kept without a room.
+func (s *homeService) DeleteRoom(userID, roomID uuid.UUID) error {
+	if _, err := s.findRoom(userID, roomID, domain.PermissionDevicesWrite); err != nil {
+		return err
+	}
+
+	return s.homeRepo.DeleteRoom(roomID)
+}
+
+func (s *homeService) ListRoomDevices(userID, roomID uuid.UUID) ([]domain.Device, error) {
+	if _, err := s.FindRoom(userID, roomID); err != nil {
+		return nil, err
+	}
+
+	devices, err := s.deviceRepo.FindByRoomIDs([]uuid.UUID{roomID})
+	if err != nil {
+		return nil, err
+	}
+
+	return activeDevices(devices), nil
+}
+
+func (s *homeService) ListHomeDevices(userID, homeID uuid.UUID) ([]domain.Device, error) {
+	rooms, err := s.ListRooms(userID, homeID)
+	if err != nil {
+		return nil, err
+	}
+
+	roomIDs := make([]uuid.UUID, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	devices, err := s.deviceRepo.FindByRoomIDs(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return activeDevices(devices), nil
+}
+
+// AssignDevice only takes the user's own devices, sharing them with
+// everyone in the home.
+func (s *homeService) AssignDevice(userID, roomID, deviceID uuid.UUID) (*domain.Device, error) {
+	room, err := s.findRoom(userID, roomID, domain.PermissionDevicesWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	device, err := s.findDevice(userID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	if device.RetiredAt != nil {
+		return nil, ErrDeviceRetired
+	}
+
+	device.RoomID = &room.ID
+
+	if err := s.deviceRepo.Update(device); err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
+
+// UnassignDevice takes any device out of the room, not only the user's
+// own, so that a device can be removed from a home it was shared with.
+func (s *homeService) UnassignDevice(userID, roomID, deviceID uuid.UUID) error {
+	if _, err := s.findRoom(userID, roomID, domain.PermissionDevicesWrite); err != nil {
+		return err
+	}
+
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err == gorm.ErrRecordNotFound {
+		return ErrDeviceNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if device.RoomID == nil || *device.RoomID != roomID {
+		return ErrDeviceNotFound
+	}
+
+	device.RoomID = nil
+
+	return s.deviceRepo.Update(device)
+}
+
+func (s *homeService) ListMembers(userID, homeID uuid.UUID) ([]domain.HomeMember, error) {
+	if _, err := s.FindHome(userID, homeID); err != nil {
+		return nil, err
+	}
+
+	return s.homeRepo.FindMembers(homeID)
+}
+
+func (s *homeService) SetMember(userID, homeID uuid.UUID, memberDTO *contract.SetHomeMemberDTO) (*domain.HomeMember, error) {
+	if err := pkg.ValidateStruct(memberDTO); err != nil {
+		return nil, err
+	}
+
+	home, err := s.findHome(userID, homeID, domain.PermissionUsersManage)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByEmail(memberDTO.Email)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == home.UserID {
+		return nil, pkg.NewInputError("the owner of a home is always its admin")
+	}
+
+	member, err := s.homeRepo.FindMember(home.ID, user.ID)
+	if err == gorm.ErrRecordNotFound {
+		member = &domain.HomeMember{HomeID: home.ID, UserID: user.ID}
+	} else if err != nil {
+		return nil, err
+	}
+
+	member.Role = memberDTO.Role
+
+	if err := s.homeRepo.SaveMember(member); err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (s *homeService) RemoveMember(userID, homeID, memberID uuid.UUID) error {
+	permission := domain.PermissionUsersManage
+	if memberID == userID {
+		permission = domain.PermissionDevicesRead
+	}
+
+	home, err := s.findHome(userID, homeID, permission)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.homeRepo.FindMember(home.ID, memberID)
+	if err == gorm.ErrRecordNotFound {
+		return ErrHomeMemberNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.homeRepo.DeleteMember(home.ID, memberID)
+}
+
+// checkHomeRole checks that the user's role in the home grants permission.
+// Users who aren't its owner nor a member get ErrHomeNotFound, members
+// whose role falls short ErrHomeAccessDenied.
+func checkHomeRole(homeRepo repository.HomeRepository, userID, homeID, ownerID uuid.UUID, permission string) error {
+	role := domain.RoleAdmin
+	if userID != ownerID {
+		member, err := homeRepo.FindMember(homeID, userID)
+		if err == gorm.ErrRecordNotFound {
+			return ErrHomeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		role = member.Role
+	}
+
+	if !domain.RoleHasPermission(role, permission) {
+		return ErrHomeAccessDenied
+	}
+
+	return nil
+}
+
+func activeDevices(devices []domain.Device) []domain.Device {
+	active := make([]domain.Device, 0, len(devices))
+	for _, device := range devices {
+		if device.RetiredAt == nil {
+			active = append(active, device)
+		}
+	}
+
+	return active
+}
+
+func (s *homeService) findDevice(userID, deviceID uuid.UUID) (*domain.Device, error) {
+	device, err := s.deviceRepo.FindByID(deviceID)
+	if err == gorm.ErrRecordNotFound || (err == nil && device.UserID != userID) {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return device, nil
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockHomeRepository struct {
+	mock.Mock
+}
+
+func (m *mockHomeRepository) CreateHome(home *domain.Home) error {
+	args := m.Called(home)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) UpdateHome(home *domain.Home) error {
+	args := m.Called(home)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) FindHomeByID(id uuid.UUID) (*domain.Home, error) {
+	args := m.Called(id)
+	if home := args.Get(0); home != nil {
+		return home.(*domain.Home), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockHomeRepository) FindHomesByUserID(userID uuid.UUID) ([]domain.Home, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]domain.Home), args.Error(1)
+}
+
+func (m *mockHomeRepository) DeleteHome(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) CreateRoom(room *domain.Room) error {
+	args := m.Called(room)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) UpdateRoom(room *domain.Room) error {
+	args := m.Called(room)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) FindRoomByID(id uuid.UUID) (*domain.Room, error) {
+	args := m.Called(id)
+	if room := args.Get(0); room != nil {
+		return room.(*domain.Room), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockHomeRepository) FindRoomsByHomeID(homeID uuid.UUID) ([]domain.Room, error) {
+	args := m.Called(homeID)
+	return args.Get(0).([]domain.Room), args.Error(1)
+}
+
+func (m *mockHomeRepository) DeleteRoom(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) FindMember(homeID, userID uuid.UUID) (*domain.HomeMember, error) {
+	args := m.Called(homeID, userID)
+	if member := args.Get(0); member != nil {
+		return member.(*domain.HomeMember), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockHomeRepository) FindMembers(homeID uuid.UUID) ([]domain.HomeMember, error) {
+	args := m.Called(homeID)
+	return args.Get(0).([]domain.HomeMember), args.Error(1)
+}
+
+func (m *mockHomeRepository) SaveMember(member *domain.HomeMember) error {
+	args := m.Called(member)
+	return args.Error(0)
+}
+
+func (m *mockHomeRepository) DeleteMember(homeID, userID uuid.UUID) error {
+	args := m.Called(homeID, userID)
+	return args.Error(0)
+}
+
+func TestHomeService_CreateHome(t *testing.T) {
+	userID := uuid.New()
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("CreateHome", mock.Anything).Return(nil)
+
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+
+	home, err := homeService.CreateHome(userID, &contract.NewHomeDTO{Name: "Beach house"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, home.UserID)
+	assert.Equal(t, "Beach house", home.Name)
+	mockRepo.AssertCalled(t, "CreateHome", home)
+}
+
+func TestHomeService_CreateHome_InvalidName(t *testing.T) {
+	mockRepo := new(mockHomeRepository)
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+
+	_, err := homeService.CreateHome(uuid.New(), &contract.NewHomeDTO{Name: ""})
+
+	assert.Equal(t, "Name is required", err.Error())
+	mockRepo.AssertNotCalled(t, "CreateHome", mock.Anything)
+}
+
+func TestHomeService_OtherOwnersHomeNotFound(t *testing.T) {
+	home := &domain.Home{ID: uuid.New(), UserID: uuid.New(), Name: "Home"}
+	missingID := uuid.New()
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+	mockRepo.On("FindHomeByID", missingID).Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("FindMember", home.ID, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+	userID := uuid.New()
+
+	_, err := homeService.FindHome(userID, home.ID)
+	assert.ErrorIs(t, err, ErrHomeNotFound)
+
+	_, err = homeService.FindHome(userID, missingID)
+	assert.ErrorIs(t, err, ErrHomeNotFound)
+
+	_, err = homeService.CreateRoom(userID, home.ID, &contract.NewRoomDTO{Name: "Kitchen"})
+	assert.ErrorIs(t, err, ErrHomeNotFound)
+
+	assert.ErrorIs(t, homeService.DeleteHome(userID, home.ID), ErrHomeNotFound)
+
+	mockRepo.AssertNotCalled(t, "CreateRoom", mock.Anything)
+	mockRepo.AssertNotCalled(t, "DeleteHome", mock.Anything)
+}
+
+func TestHomeService_CreateRoom(t *testing.T) {
+	userID := uuid.New()
+	home := &domain.Home{ID: uuid.New(), UserID: userID, Name: "Home"}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+	mockRepo.On("CreateRoom", mock.Anything).Return(nil)
+
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+
+	room, err := homeService.CreateRoom(userID, home.ID, &contract.NewRoomDTO{Name: "Kitchen"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, home.ID, room.HomeID)
+	assert.Equal(t, userID, room.UserID)
+	assert.Equal(t, "Kitchen", room.Name)
+}
+
+func TestHomeService_AssignDevice(t *testing.T) {
+	userID := uuid.New()
+	room := &domain.Room{ID: uuid.New(), HomeID: uuid.New(), UserID: userID, Name: "Kitchen"}
+	device := &domain.Device{ID: uuid.New(), UserID: userID}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindRoomByID", room.ID).Return(room, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", device.ID).Return(device, nil)
+	mockDeviceRepo.On("Update", device).Return(nil)
+
+	homeService := NewHomeService(mockRepo, mockDeviceRepo, new(mockUserRepository))
+
+	assigned, err := homeService.AssignDevice(userID, room.ID, device.ID)
+
+	require.NoError(t, err)
+	assert.Equal(t, room.ID, *assigned.RoomID)
+
+	err = homeService.UnassignDevice(userID, room.ID, device.ID)
+
+	assert.NoError(t, err)
+	assert.Nil(t, device.RoomID)
+	mockDeviceRepo.AssertNumberOfCalls(t, "Update", 2)
+}
+
+func TestHomeService_AssignDevice_Rejected(t *testing.T) {
+	userID := uuid.New()
+	room := &domain.Room{ID: uuid.New(), UserID: userID}
+	retiredAt := time.Now()
+	otherRoomID := uuid.New()
+
+	tests := []struct {
+		name   string
+		device *domain.Device
+		err    error
+	}{
+		{"other owner", &domain.Device{ID: uuid.New(), UserID: uuid.New()}, ErrDeviceNotFound},
+		{"retired", &domain.Device{ID: uuid.New(), UserID: userID, RetiredAt: &retiredAt}, ErrDeviceRetired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockHomeRepository)
+			mockRepo.On("FindRoomByID", room.ID).Return(room, nil)
+
+			mockDeviceRepo := new(mockDeviceRepository)
+			mockDeviceRepo.On("FindByID", tt.device.ID).Return(tt.device, nil)
+
+			homeService := NewHomeService(mockRepo, mockDeviceRepo, new(mockUserRepository))
+
+			_, err := homeService.AssignDevice(userID, room.ID, tt.device.ID)
+
+			assert.ErrorIs(t, err, tt.err)
+			mockDeviceRepo.AssertNotCalled(t, "Update", mock.Anything)
+		})
+	}
+
+	t.Run("unassign from another room", func(t *testing.T) {
+		device := &domain.Device{ID: uuid.New(), UserID: userID, RoomID: &otherRoomID}
+
+		mockRepo := new(mockHomeRepository)
+		mockRepo.On("FindRoomByID", room.ID).Return(room, nil)
+
+		mockDeviceRepo := new(mockDeviceRepository)
+		mockDeviceRepo.On("FindByID", device.ID).Return(device, nil)
+
+		homeService := NewHomeService(mockRepo, mockDeviceRepo, new(mockUserRepository))
+
+		err := homeService.UnassignDevice(userID, room.ID, device.ID)
+
+		assert.ErrorIs(t, err, ErrDeviceNotFound)
+		assert.Equal(t, otherRoomID, *device.RoomID)
+	})
+}
+
+func TestHomeService_ListRoomDevices_SkipsRetired(t *testing.T) {
+	userID := uuid.New()
+	room := &domain.Room{ID: uuid.New(), UserID: userID}
+	retiredAt := time.Now()
+	active := domain.Device{ID: uuid.New(), UserID: userID, RoomID: &room.ID}
+	retired := domain.Device{ID: uuid.New(), UserID: userID, RoomID: &room.ID, RetiredAt: &retiredAt}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindRoomByID", room.ID).Return(room, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByRoomIDs", []uuid.UUID{room.ID}).Return([]domain.Device{active, retired}, nil)
+
+	homeService := NewHomeService(mockRepo, mockDeviceRepo, new(mockUserRepository))
+
+	devices, err := homeService.ListRoomDevices(userID, room.ID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Device{active}, devices)
+}
+
+func TestHomeService_ListHomeDevices(t *testing.T) {
+	userID := uuid.New()
+	home := &domain.Home{ID: uuid.New(), UserID: userID}
+	kitchen := domain.Room{ID: uuid.New(), HomeID: home.ID, UserID: userID}
+	bedroom := domain.Room{ID: uuid.New(), HomeID: home.ID, UserID: userID}
+	retiredAt := time.Now()
+	inKitchen := domain.Device{ID: uuid.New(), UserID: userID, RoomID: &kitchen.ID}
+	inBedroom := domain.Device{ID: uuid.New(), UserID: userID, RoomID: &bedroom.ID}
+	retired := domain.Device{ID: uuid.New(), UserID: userID, RoomID: &bedroom.ID, RetiredAt: &retiredAt}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+	mockRepo.On("FindRoomsByHomeID", home.ID).Return([]domain.Room{kitchen, bedroom}, nil)
+	mockRepo.On("FindMember", home.ID, mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByRoomIDs", []uuid.UUID{kitchen.ID, bedroom.ID}).Return([]domain.Device{inKitchen, inBedroom, retired}, nil)
+
+	homeService := NewHomeService(mockRepo, mockDeviceRepo, new(mockUserRepository))
+
+	devices, err := homeService.ListHomeDevices(userID, home.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Device{inKitchen, inBedroom}, devices)
+
+	_, err = homeService.ListHomeDevices(uuid.New(), home.ID)
+	assert.ErrorIs(t, err, ErrHomeNotFound)
+}
+
+func TestHomeService_MemberRoles(t *testing.T) {
+	home := &domain.Home{ID: uuid.New(), UserID: uuid.New(), Name: "Home"}
+	room := &domain.Room{ID: uuid.New(), HomeID: home.ID, UserID: home.UserID, Name: "Kitchen"}
+
+	tests := []struct {
+		role      string
+		writeErr  error
+		manageErr error
+	}{
+		{domain.RoleViewer, ErrHomeAccessDenied, ErrHomeAccessDenied},
+		{domain.RoleMember, nil, ErrHomeAccessDenied},
+		{domain.RoleAdmin, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.role, func(t *testing.T) {
+			userID := uuid.New()
+
+			mockRepo := new(mockHomeRepository)
+			mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+			mockRepo.On("FindRoomByID", room.ID).Return(room, nil)
+			mockRepo.On("FindMember", home.ID, userID).Return(&domain.HomeMember{HomeID: home.ID, UserID: userID, Role: tt.role}, nil)
+			mockRepo.On("UpdateRoom", room).Return(nil)
+			mockRepo.On("DeleteHome", home.ID).Return(nil)
+
+			homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+
+			found, err := homeService.FindHome(userID, home.ID)
+			require.NoError(t, err)
+			assert.Equal(t, home, found)
+
+			_, err = homeService.FindRoom(userID, room.ID)
+			assert.NoError(t, err)
+
+			name := "Pantry"
+			_, err = homeService.UpdateRoom(userID, room.ID, &contract.UpdateRoomDTO{Name: &name})
+			assert.Equal(t, tt.writeErr, err)
+
+			assert.Equal(t, tt.manageErr, homeService.DeleteHome(userID, home.ID))
+		})
+	}
+}
+
+func TestHomeService_SetMember(t *testing.T) {
+	ownerID := uuid.New()
+	home := &domain.Home{ID: uuid.New(), UserID: ownerID}
+	user := &domain.User{ID: uuid.New(), Email: "guest@example.com"}
+	owner := &domain.User{ID: ownerID, Email: "owner@example.com"}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+	mockRepo.On("FindMember", home.ID, user.ID).Return(nil, gorm.ErrRecordNotFound)
+	mockRepo.On("SaveMember", mock.Anything).Return(nil)
+
+	mockUserRepo := new(mockUserRepository)
+	mockUserRepo.On("FindByEmail", user.Email).Return(user, nil)
+	mockUserRepo.On("FindByEmail", owner.Email).Return(owner, nil)
+	mockUserRepo.On("FindByEmail", "nobody@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), mockUserRepo)
+
+	member, err := homeService.SetMember(ownerID, home.ID, &contract.SetHomeMemberDTO{Email: user.Email, Role: domain.RoleViewer})
+
+	require.NoError(t, err)
+	assert.Equal(t, home.ID, member.HomeID)
+	assert.Equal(t, user.ID, member.UserID)
+	assert.Equal(t, domain.RoleViewer, member.Role)
+	mockRepo.AssertCalled(t, "SaveMember", member)
+
+	_, err = homeService.SetMember(ownerID, home.ID, &contract.SetHomeMemberDTO{Email: owner.Email, Role: domain.RoleViewer})
+	assert.Equal(t, "the owner of a home is always its admin", err.Error())
+
+	_, err = homeService.SetMember(ownerID, home.ID, &contract.SetHomeMemberDTO{Email: "nobody@example.com", Role: domain.RoleViewer})
+	assert.ErrorIs(t, err, ErrUserNotFound)
+
+	_, err = homeService.SetMember(ownerID, home.ID, &contract.SetHomeMemberDTO{Email: user.Email, Role: "owner"})
+	assert.Error(t, err)
+
+	mockRepo.AssertNumberOfCalls(t, "SaveMember", 1)
+}
+
+func TestHomeService_RemoveMember(t *testing.T) {
+	home := &domain.Home{ID: uuid.New(), UserID: uuid.New()}
+	viewer := &domain.HomeMember{HomeID: home.ID, UserID: uuid.New(), Role: domain.RoleViewer}
+	other := &domain.HomeMember{HomeID: home.ID, UserID: uuid.New(), Role: domain.RoleMember}
+
+	mockRepo := new(mockHomeRepository)
+	mockRepo.On("FindHomeByID", home.ID).Return(home, nil)
+	mockRepo.On("FindMember", home.ID, viewer.UserID).Return(viewer, nil)
+	mockRepo.On("FindMember", home.ID, other.UserID).Return(other, nil)
+	mockRepo.On("DeleteMember", home.ID, viewer.UserID).Return(nil)
+	mockRepo.On("DeleteMember", home.ID, other.UserID).Return(nil)
+
+	homeService := NewHomeService(mockRepo, new(mockDeviceRepository), new(mockUserRepository))
+
+	assert.ErrorIs(t, homeService.RemoveMember(viewer.UserID, home.ID, other.UserID), ErrHomeAccessDenied)
+	assert.NoError(t, homeService.RemoveMember(viewer.UserID, home.ID, viewer.UserID))
+	assert.NoError(t, homeService.RemoveMember(home.UserID, home.ID, other.UserID))
+
+	mockRepo.AssertNotCalled(t, "DeleteMember", home.ID, home.UserID)
+	mockRepo.AssertNumberOfCalls(t, "DeleteMember", 2)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrTooManyAttempts is wrapped by every LoginLockedError.
+var ErrTooManyAttempts = errors.New("too many failed login attempts")
+
+// LoginLockedError is returned while an account or IP address is locked
+// out after too many failed logins.
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("%s, try again in %s", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LoginLockedError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+var (
+	dummyHash     []byte
+	dummyHashOnce sync.Once
+)
+
+// compareDummyPassword spends as long as checking a real password, so
+// unknown emails can't be told apart by response time.
+func compareDummyPassword(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("thermosync-dummy-password"), bcrypt.DefaultCost)
+	})
+
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+func accountThrottleKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// checkLoginThrottle returns a LoginLockedError while the account or the
+// client IP is locked out. Unknown emails are throttled like registered
+// ones so lockouts don't reveal which exist.
+func (s *authService) checkLoginThrottle(email string, client ClientInfo, now time.Time) error {
+	retryAfter := s.accountThrottle.RetryAfter(accountThrottleKey(email), now)
+
+	if client.IPAddress != "" {
+		if ipRetryAfter := s.ipThrottle.RetryAfter(client.IPAddress, now); ipRetryAfter > retryAfter {
+			retryAfter = ipRetryAfter
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// loginFailed counts a failed login against the account and the client IP
+// and audits the lockouts it causes. user is nil for unknown emails.
+func (s *authService) loginFailed(user *domain.User, email string, client ClientInfo, now time.Time) error {
+	if s.accountThrottle.Fail(accountThrottleKey(email), now) {
+		s.audit(domain.AuditLoginAccountLocked, user, email, client, now)
+	}
+
+	if client.IPAddress != "" && s.ipThrottle.Fail(client.IPAddress, now) {
+		s.audit(domain.AuditLoginIPLocked, user, email, client, now)
+	}
+
+	return ErrInvalidCredentials
+}
+
+// audit records an event, failing to do so is logged rather than failing
+// the request it happened in.
+func (s *authService) audit(eventType string, user *domain.User, email string, client ClientInfo, now time.Time) {
+	event := &domain.AuditEvent{
+		ID:        uuid.New(),
+		Type:      eventType,
+		Email:     truncate(email, 255),
+		IPAddress: client.IPAddress,
+		UserAgent: truncate(client.UserAgent, 255),
+		CreatedAt: now,
+	}
+	if user != nil {
+		event.UserID = &user.ID
+	}
+
+	if err := s.auditRepo.Create(event); err != nil {
+		log.Printf("Failed to record %s audit event: %s", eventType, err)
+	}
+}
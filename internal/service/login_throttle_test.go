@@ -0,0 +1,106 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/throttle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"gorm.io/gorm"
+)
+
+func newThrottledAuthService(accountFailures, ipFailures int) (AuthService, *authServiceMocks) {
+	config := DefaultAuthConfig()
+	config.AccountThrottle = throttle.Config{MaxFailures: accountFailures, Window: time.Hour, Lockout: time.Minute, MaxLockout: time.Hour}
+	config.IPThrottle = throttle.Config{MaxFailures: ipFailures, Window: time.Hour, Lockout: time.Minute, MaxLockout: time.Hour}
+
+	return newTestAuthServiceWithConfig(config)
+}
+
+func TestAuthService_Login_LocksOutAccount(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	client := ClientInfo{IPAddress: "203.0.113.7"}
+
+	authService, mocks := newThrottledAuthService(3, 100)
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.audit.On("Create", mock.Anything).Return(nil)
+
+	for i := 0; i < 3; i++ {
+		_, _, err := authService.Login(user.Email, "wrong-password", client)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	// Even the right password is refused while locked out.
+	_, _, err := authService.Login("SENNA@example.com", "supersenha", client)
+
+	var locked *LoginLockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	assert.InDelta(t, time.Minute, locked.RetryAfter, float64(time.Second))
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+
+	mocks.audit.AssertNumberOfCalls(t, "Create", 1)
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Equal(t, domain.AuditLoginAccountLocked, event.Type)
+	assert.Equal(t, user.ID, *event.UserID)
+	assert.Equal(t, "203.0.113.7", event.IPAddress)
+}
+
+func TestAuthService_Login_LocksOutUnknownEmail(t *testing.T) {
+	authService, mocks := newThrottledAuthService(2, 100)
+	mocks.users.On("FindByEmail", "nobody@example.com").Return(nil, gorm.ErrRecordNotFound)
+	mocks.audit.On("Create", mock.Anything).Return(nil)
+
+	for i := 0; i < 2; i++ {
+		_, _, err := authService.Login("nobody@example.com", "supersenha", ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, _, err := authService.Login("nobody@example.com", "supersenha", ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Nil(t, event.UserID)
+	assert.Equal(t, "nobody@example.com", event.Email)
+}
+
+func TestAuthService_Login_LocksOutIP(t *testing.T) {
+	client := ClientInfo{IPAddress: "203.0.113.7"}
+
+	authService, mocks := newThrottledAuthService(100, 3)
+	mocks.users.On("FindByEmail", mock.Anything).Return(nil, gorm.ErrRecordNotFound)
+	mocks.audit.On("Create", mock.Anything).Return(nil)
+
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		_, _, err := authService.Login(email, "supersenha", client)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+
+	_, _, err := authService.Login("d@example.com", "supersenha", client)
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	_, _, err = authService.Login("d@example.com", "supersenha", ClientInfo{IPAddress: "198.51.100.1"})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Equal(t, domain.AuditLoginIPLocked, event.Type)
+}
+
+func TestAuthService_Login_SuccessResetsFailures(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	authService, mocks := newThrottledAuthService(2, 100)
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.sessions.On("Create", mock.Anything).Return(nil)
+	mocks.refreshTokens.On("Create", mock.Anything).Return(nil)
+
+	authService.Login(user.Email, "wrong-password", ClientInfo{})
+	_, _, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	assert.NoError(t, err)
+
+	_, _, err = authService.Login(user.Email, "wrong-password", ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	mocks.audit.AssertNumberOfCalls(t, "Create", 0)
+}
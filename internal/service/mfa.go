@@ -0,0 +1,318 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/internal/totp"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengePurpose = "mfa-challenge"
+	recoveryCodeCount   = 10
+)
+
+var (
+	ErrInvalidMFAToken   = errors.New("invalid or expired MFA challenge")
+	ErrInvalidMFACode    = errors.New("invalid MFA code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP generates a new TOTP secret for the user, it only protects
+// logins once ConfirmTOTP proved the authenticator app was set up.
+func (s *authService) EnrollTOTP(userID uuid.UUID) (*contract.TOTPEnrollmentDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPSecret = secret
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &contract.TOTPEnrollmentDTO{
+		Secret: secret,
+		URI:    totp.URI(s.config.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication given a first code from
+// the enrolled secret and returns the recovery codes, they are only shown
+// this once.
+func (s *authService) ConfirmTOTP(userID uuid.UUID, code string) (*contract.RecoveryCodesDTO, error) {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsMFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrMFANotEnabled
+	}
+
+	now := s.now()
+
+	step, ok := totp.Validate(user.TOTPSecret, code, now)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := s.replaceRecoveryCodes(user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabledAt = &now
+	user.TOTPLastStep = step
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	return &contract.RecoveryCodesDTO{RecoveryCodes: codes}, nil
+}
+
+// DisableTOTP turns two-factor authentication off, it takes both the
+// password and a current code so a stolen session alone can't do it.
+func (s *authService) DisableTOTP(userID uuid.UUID, disableDTO *contract.DisableTOTPDTO) error {
+	user, err := s.findUser(userID)
+	if err != nil {
+		return err
+	}
+	if !user.IsMFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(disableDTO.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+	if err := s.checkMFACode(user, disableDTO.Code, s.now()); err != nil {
+		return err
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabledAt = nil
+	user.TOTPLastStep = 0
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.recoveryCodeRepo.DeleteByUserID(user.ID)
+}
+
+// VerifyMFA completes a login that returned an MFA challenge. Wrong codes
+// are throttled per user like wrong passwords.
+func (s *authService) VerifyMFA(challengeToken string, code string, client ClientInfo) (*contract.TokenResponseDTO, error) {
+	claims, err := s.tokens.VerifyFor(mfaChallengePurpose, challengeToken)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+
+	now := s.now()
+	throttleKey := "mfa:" + claims.UserID.String()
+
+	if retryAfter := s.accountThrottle.RetryAfter(throttleKey, now); retryAfter > 0 {
+		return nil, &LoginLockedError{RetryAfter: retryAfter}
+	}
+
+	used, err := s.revocations.IsRevoked(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if used {
+		return nil, ErrInvalidMFAToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrInvalidMFAToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsMFAEnabled() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	err = s.checkMFACode(user, code, now)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if s.accountThrottle.Fail(throttleKey, now) {
+			s.audit(domain.AuditMFALocked, user, user.Email, client, now)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.accountThrottle.Reset(throttleKey)
+
+	if err := s.revocations.Revoke(claims.ID, claims.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(user, client)
+}
+
+func (s *authService) mfaChallenge(user *domain.User) (*contract.MFAChallengeDTO, error) {
+	challengeToken, claims, err := s.tokens.IssueFor(mfaChallengePurpose, token.Claims{UserID: user.ID}, s.config.MFAChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contract.MFAChallengeDTO{
+		MFARequired: true,
+		MFAToken:    challengeToken,
+		ExpiresIn:   int(claims.ExpiresAt.Sub(claims.IssuedAt).Seconds()),
+	}, nil
+}
+
+// ConfirmRecentLogin lets a sensitive change through when the session
+// signed in within RecentLoginWindow, or with an MFA code. Wrong codes are
+// throttled like in VerifyMFA.
+func (s *authService) ConfirmRecentLogin(user *domain.User, sessionID uuid.UUID, code string) error {
+	now := s.now()
+
+	if code == "" {
+		session, err := s.sessionRepo.FindByID(sessionID)
+		if err == gorm.ErrRecordNotFound {
+			return ErrReauthenticationRequired
+		}
+		if err != nil {
+			return err
+		}
+
+		if session.UserID != user.ID || session.RevokedAt != nil || now.Sub(session.CreatedAt) > s.config.RecentLoginWindow {
+			return ErrReauthenticationRequired
+		}
+
+		return nil
+	}
+
+	if !user.IsMFAEnabled() {
+		return ErrMFANotEnabled
+	}
+
+	throttleKey := "mfa:" + user.ID.String()
+	if retryAfter := s.accountThrottle.RetryAfter(throttleKey, now); retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+
+	err := s.checkMFACode(user, code, now)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if s.accountThrottle.Fail(throttleKey, now) {
+			s.audit(domain.AuditMFALocked, user, user.Email, ClientInfo{}, now)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	s.accountThrottle.Reset(throttleKey)
+
+	return nil
+}
+
+// checkMFACode accepts a TOTP code newer than the last one accepted, or
+// an unused recovery code.
+func (s *authService) checkMFACode(user *domain.User, code string, now time.Time) error {
+	code = normalizeMFACode(code)
+
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(user.TOTPSecret, code, now)
+		if !ok || step <= user.TOTPLastStep {
+			return ErrInvalidMFACode
+		}
+
+		// The check above only reflects what was read, the update decides
+		// between concurrent uses of the same code.
+		used, err := s.userRepo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+
+		user.TOTPLastStep = step
+		return nil
+	}
+
+	used, err := s.recoveryCodeRepo.Use(user.ID, pkg.HashSecret(code), now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// replaceRecoveryCodes stores new recovery codes for the user and returns
+// them formatted as xxxxx-xxxxx.
+func (s *authService) replaceRecoveryCodes(userID uuid.UUID, now time.Time) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	stored := make([]domain.RecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(recoveryCodeEncoding.EncodeToString(buf))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		stored = append(stored, domain.RecoveryCode{
+			ID:        uuid.New(),
+			UserID:    userID,
+			CodeHash:  pkg.HashSecret(code),
+			CreatedAt: now,
+		})
+	}
+
+	if err := s.recoveryCodeRepo.Replace(userID, stored); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func normalizeMFACode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func (s *authService) findUser(id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
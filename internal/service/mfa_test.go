@@ -0,0 +1,234 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/token"
+	"github.com/azevedoguigo/thermosync-api/internal/totp"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockRecoveryCodeRepository struct {
+	mock.Mock
+}
+
+func (m *mockRecoveryCodeRepository) Replace(userID uuid.UUID, codes []domain.RecoveryCode) error {
+	args := m.Called(userID, codes)
+	return args.Error(0)
+}
+
+func (m *mockRecoveryCodeRepository) Use(userID uuid.UUID, codeHash string, usedAt time.Time) (bool, error) {
+	args := m.Called(userID, codeHash, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockRecoveryCodeRepository) DeleteByUserID(userID uuid.UUID) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+// newMFAUser returns a user with TOTP enabled and the current code for it.
+func newMFAUser(t *testing.T) (*domain.User, string) {
+	user := newTestUser(t, "supersenha")
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	enabledAt := time.Now()
+	user.TOTPSecret = secret
+	user.TOTPEnabledAt = &enabledAt
+
+	code, err := totp.Code(secret, time.Now())
+	require.NoError(t, err)
+
+	return user, code
+}
+
+func TestAuthService_EnrollAndConfirmTOTP(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("Update", mock.Anything).Return(nil)
+	mocks.recoveryCodes.On("Replace", user.ID, mock.Anything).Return(nil)
+
+	enrollment, err := authService.EnrollTOTP(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, user.TOTPSecret, enrollment.Secret)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/ThermoSync:senna@example.com?")
+	assert.False(t, user.IsMFAEnabled())
+
+	_, err = authService.ConfirmTOTP(user.ID, "000000")
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+	assert.False(t, user.IsMFAEnabled())
+
+	code, err := totp.Code(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+
+	recoveryCodes, err := authService.ConfirmTOTP(user.ID, code)
+	require.NoError(t, err)
+	assert.True(t, user.IsMFAEnabled())
+	assert.Len(t, recoveryCodes.RecoveryCodes, recoveryCodeCount)
+
+	stored := mocks.recoveryCodes.Calls[0].Arguments.Get(1).([]domain.RecoveryCode)
+	assert.Equal(t, pkg.HashSecret(normalizeMFACode(recoveryCodes.RecoveryCodes[0])), stored[0].CodeHash)
+
+	_, err = authService.EnrollTOTP(user.ID)
+	assert.ErrorIs(t, err, ErrMFAAlreadyEnabled)
+}
+
+func TestAuthService_Login_ReturnsMFAChallenge(t *testing.T) {
+	user, code := newMFAUser(t)
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("UseTOTPStep", user.ID, mock.Anything).Return(true, nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+	mocks.revocations.On("Revoke", mock.Anything, mock.Anything).Return(nil)
+	mocks.sessions.On("Create", mock.Anything).Return(nil)
+	mocks.refreshTokens.On("Create", mock.Anything).Return(nil)
+
+	tokens, challenge, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+	assert.Nil(t, tokens)
+	assert.True(t, challenge.MFARequired)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+
+	// The challenge isn't an access token.
+	_, err = testTokens.Verify(challenge.MFAToken)
+	assert.Error(t, err)
+
+	tokens, err = authService.VerifyMFA(challenge.MFAToken, code, ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 1)
+	mocks.revocations.AssertCalled(t, "Revoke", mock.Anything, mock.Anything)
+}
+
+func TestAuthService_VerifyMFA_RejectsReplayedCode(t *testing.T) {
+	user, code := newMFAUser(t)
+	user.TOTPLastStep = totp.Step(time.Now()) + totp.Skew
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+
+	_, challenge, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+
+	_, err = authService.VerifyMFA(challenge.MFAToken, code, ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_VerifyMFA_RejectsCodeUsedConcurrently(t *testing.T) {
+	user, code := newMFAUser(t)
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+	// Another request stored the step between the read and the update.
+	mocks.users.On("UseTOTPStep", user.ID, mock.Anything).Return(false, nil)
+
+	_, challenge, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+
+	_, err = authService.VerifyMFA(challenge.MFAToken, code, ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+	mocks.sessions.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_VerifyMFA_RecoveryCode(t *testing.T) {
+	user, _ := newMFAUser(t)
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+	mocks.revocations.On("Revoke", mock.Anything, mock.Anything).Return(nil)
+	mocks.recoveryCodes.On("Use", user.ID, pkg.HashSecret("abcdefghij"), mock.Anything).Return(true, nil).Once()
+	mocks.recoveryCodes.On("Use", user.ID, pkg.HashSecret("abcdefghij"), mock.Anything).Return(false, nil)
+	mocks.sessions.On("Create", mock.Anything).Return(nil)
+	mocks.refreshTokens.On("Create", mock.Anything).Return(nil)
+
+	_, challenge, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+
+	_, err = authService.VerifyMFA(challenge.MFAToken, "ABCDE-FGHIJ", ClientInfo{})
+	assert.NoError(t, err)
+
+	_, challenge, err = authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+
+	_, err = authService.VerifyMFA(challenge.MFAToken, "abcde-fghij", ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidMFACode)
+}
+
+func TestAuthService_VerifyMFA_LocksOutAfterFailures(t *testing.T) {
+	user, _ := newMFAUser(t)
+
+	authService, mocks := newThrottledAuthService(3, 100)
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.revocations.On("IsRevoked", mock.Anything).Return(false, nil)
+	mocks.audit.On("Create", mock.Anything).Return(nil)
+
+	_, challenge, err := authService.Login(user.Email, "supersenha", ClientInfo{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = authService.VerifyMFA(challenge.MFAToken, "000000", ClientInfo{})
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	}
+
+	_, err = authService.VerifyMFA(challenge.MFAToken, "000000", ClientInfo{})
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	event := mocks.audit.Calls[0].Arguments.Get(0).(*domain.AuditEvent)
+	assert.Equal(t, domain.AuditMFALocked, event.Type)
+}
+
+func TestAuthService_VerifyMFA_InvalidChallenge(t *testing.T) {
+	user, code := newMFAUser(t)
+
+	accessToken, _, err := testTokens.Issue(token.Claims{UserID: user.ID})
+	require.NoError(t, err)
+
+	authService, _ := newTestAuthService()
+
+	_, err = authService.VerifyMFA(accessToken, code, ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidMFAToken)
+}
+
+func TestAuthService_DisableTOTP(t *testing.T) {
+	user, code := newMFAUser(t)
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("UseTOTPStep", user.ID, mock.Anything).Return(true, nil)
+	mocks.users.On("Update", mock.Anything).Return(nil)
+	mocks.recoveryCodes.On("DeleteByUserID", user.ID).Return(nil)
+
+	err := authService.DisableTOTP(user.ID, &contract.DisableTOTPDTO{Password: "wrong-password", Code: code})
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+	assert.True(t, user.IsMFAEnabled())
+
+	err = authService.DisableTOTP(user.ID, &contract.DisableTOTPDTO{Password: "supersenha", Code: code})
+	assert.NoError(t, err)
+	assert.False(t, user.IsMFAEnabled())
+	assert.Empty(t, user.TOTPSecret)
+	mocks.recoveryCodes.AssertCalled(t, "DeleteByUserID", user.ID)
+}
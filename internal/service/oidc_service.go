@@ -0,0 +1,199 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/oidc"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// oidcLoginTTL is how long a user has to sign in at the provider.
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown sign in provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired sign in state")
+	ErrOIDCLoginFailed     = errors.New("sign in with the provider failed")
+	// ErrOIDCEmailNotVerified is returned for identities without an email
+	// the provider verified, they can't be matched to accounts safely.
+	ErrOIDCEmailNotVerified = errors.New("the provider did not verify the email")
+	// ErrOIDCAccountNotVerified keeps an identity from being linked to an
+	// account whose owner never proved the email is theirs, anyone could
+	// have registered it with a password of their choosing beforehand.
+	ErrOIDCAccountNotVerified = errors.New("an unverified account uses this email, verify it or reset its password first")
+)
+
+// LoginCompleter finishes a login once the user proved who they are,
+// AuthService implements it.
+type LoginCompleter interface {
+	CompleteLogin(user *domain.User, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error)
+}
+
+// OIDCProvider is the part of oidc.Provider the service uses.
+type OIDCProvider interface {
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	Exchange(code, codeVerifier, nonce string) (*oidc.Identity, error)
+}
+
+type OIDCService interface {
+	StartLogin(provider string) (*contract.OIDCAuthorizationDTO, error)
+	// CompleteLogin signs in the user of an external identity, linking it
+	// to the account with the same email or creating one. Accounts whose
+	// email was never verified are not linked.
+	CompleteLogin(provider string, callbackDTO *contract.OIDCCallbackDTO, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error)
+}
+
+type oidcService struct {
+	userRepo     repository.UserRepository
+	identityRepo repository.ExternalIdentityRepository
+	providers    map[string]OIDCProvider
+	logins       LoginCompleter
+	now          func() time.Time
+}
+
+func NewOIDCService(
+	userRepo repository.UserRepository,
+	identityRepo repository.ExternalIdentityRepository,
+	providers map[string]OIDCProvider,
+	logins LoginCompleter,
+) OIDCService {
+	return &oidcService{
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		providers:    providers,
+		logins:       logins,
+		now:          time.Now,
+	}
+}
+
+func (s *oidcService) StartLogin(providerName string) (*contract.OIDCAuthorizationDTO, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	state, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	codeVerifier, err := pkg.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	authURL, err := provider.AuthCodeURL(state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+
+	err = s.identityRepo.CreateLoginState(&domain.OIDCLoginState{
+		ID:           uuid.New(),
+		Provider:     providerName,
+		StateHash:    pkg.HashSecret(state),
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(oidcLoginTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &contract.OIDCAuthorizationDTO{AuthorizationURL: authURL, State: state}, nil
+}
+
+func (s *oidcService) CompleteLogin(providerName string, callbackDTO *contract.OIDCCallbackDTO, client ClientInfo) (*contract.TokenResponseDTO, *contract.MFAChallengeDTO, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, nil, ErrUnknownOIDCProvider
+	}
+
+	state, err := s.identityRepo.ConsumeLoginState(pkg.HashSecret(callbackDTO.State))
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if state.Provider != providerName || !s.now().Before(state.ExpiresAt) {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	identity, err := provider.Exchange(callbackDTO.Code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Sign in with %s failed: %s", providerName, err)
+		return nil, nil, ErrOIDCLoginFailed
+	}
+
+	user, err := s.userForIdentity(providerName, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return s.logins.CompleteLogin(user, client)
+}
+
+func (s *oidcService) userForIdentity(providerName string, identity *oidc.Identity) (*domain.User, error) {
+	linked, err := s.identityRepo.FindBySubject(providerName, identity.Subject)
+	if err == nil {
+		return s.userRepo.FindByID(linked.UserID)
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	now := s.now()
+
+	user, err := s.userRepo.FindByEmail(identity.Email)
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		user = &domain.User{
+			ID:              uuid.New(),
+			FirstName:       identity.GivenName,
+			LastName:        identity.FamilyName,
+			Email:           identity.Email,
+			TemperatureUnit: domain.UnitCelsius,
+			Role:            domain.RoleMember,
+			// Users created here have no password until they reset one.
+			EmailVerifiedAt: &now,
+		}
+		if err := s.userRepo.Create(user); err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	case !user.IsEmailVerified():
+		return nil, ErrOIDCAccountNotVerified
+	}
+
+	err = s.identityRepo.Create(&domain.ExternalIdentity{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		Provider:  providerName,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,257 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/oidc"
+	"github.com/azevedoguigo/thermosync-api/internal/oidc/oidctest"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockExternalIdentityRepository struct {
+	mock.Mock
+	states map[string]*domain.OIDCLoginState
+}
+
+func (m *mockExternalIdentityRepository) Create(identity *domain.ExternalIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *mockExternalIdentityRepository) FindBySubject(provider, subject string) (*domain.ExternalIdentity, error) {
+	args := m.Called(provider, subject)
+	if identity := args.Get(0); identity != nil {
+		return identity.(*domain.ExternalIdentity), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockExternalIdentityRepository) CreateLoginState(state *domain.OIDCLoginState) error {
+	m.states[state.StateHash] = state
+	return nil
+}
+
+func (m *mockExternalIdentityRepository) ConsumeLoginState(stateHash string) (*domain.OIDCLoginState, error) {
+	state, ok := m.states[stateHash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(m.states, stateHash)
+	return state, nil
+}
+
+func (m *mockExternalIdentityRepository) DeleteExpiredLoginStates(now time.Time) error {
+	args := m.Called(now)
+	return args.Error(0)
+}
+
+var sennaIdentity = oidc.Identity{
+	Subject:       "senna-1988",
+	Email:         "senna@example.com",
+	EmailVerified: true,
+	GivenName:     "Ayrton",
+	FamilyName:    "Senna",
+}
+
+type oidcTestEnv struct {
+	service    OIDCService
+	provider   *oidctest.Server
+	identities *mockExternalIdentityRepository
+	auth       *authServiceMocks
+}
+
+func newTestOIDCService(t *testing.T) *oidcTestEnv {
+	server := oidctest.NewServer()
+	t.Cleanup(server.Close)
+
+	provider, err := oidc.NewProvider(server.Config("test"), nil)
+	require.NoError(t, err)
+
+	authService, authMocks := newTestAuthService()
+	authMocks.sessions.On("Create", mock.Anything).Return(nil)
+	authMocks.refreshTokens.On("Create", mock.Anything).Return(nil)
+
+	identities := &mockExternalIdentityRepository{states: make(map[string]*domain.OIDCLoginState)}
+
+	return &oidcTestEnv{
+		service:    NewOIDCService(authMocks.users, identities, map[string]OIDCProvider{"test": provider}, authService),
+		provider:   server,
+		identities: identities,
+		auth:       authMocks,
+	}
+}
+
+// signIn runs the flow up to the app posting the callback.
+func (env *oidcTestEnv) signIn(t *testing.T, identity oidc.Identity) *contract.OIDCCallbackDTO {
+	authorization, err := env.service.StartLogin("test")
+	require.NoError(t, err)
+
+	code, state, err := env.provider.SignIn(authorization.AuthorizationURL, identity)
+	require.NoError(t, err)
+	assert.Equal(t, authorization.State, state)
+
+	return &contract.OIDCCallbackDTO{Code: code, State: state}
+}
+
+func TestOIDCService_FirstLoginCreatesUser(t *testing.T) {
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(nil, gorm.ErrRecordNotFound)
+	env.identities.On("Create", mock.Anything).Return(nil)
+	env.auth.users.On("FindByEmail", "senna@example.com").Return(nil, gorm.ErrRecordNotFound)
+	env.auth.users.On("Create", mock.Anything).Return(nil)
+
+	tokens, challenge, err := env.service.CompleteLogin("test", env.signIn(t, sennaIdentity), ClientInfo{})
+
+	require.NoError(t, err)
+	assert.Nil(t, challenge)
+
+	created := env.auth.users.Calls[1].Arguments.Get(0).(*domain.User)
+	assert.Equal(t, "Ayrton", created.FirstName)
+	assert.Equal(t, "Senna", created.LastName)
+	assert.True(t, created.IsEmailVerified())
+	assert.Empty(t, created.Password)
+
+	linked := env.identities.Calls[1].Arguments.Get(0).(*domain.ExternalIdentity)
+	assert.Equal(t, created.ID, linked.UserID)
+	assert.Equal(t, "senna-1988", linked.Subject)
+
+	claims, err := testTokens.Verify(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, claims.UserID)
+}
+
+func TestOIDCService_LinkedIdentityLogsIn(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(&domain.ExternalIdentity{UserID: user.ID}, nil)
+	env.auth.users.On("FindByID", user.ID).Return(user, nil)
+
+	tokens, _, err := env.service.CompleteLogin("test", env.signIn(t, sennaIdentity), ClientInfo{})
+
+	require.NoError(t, err)
+	claims, err := testTokens.Verify(tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, claims.UserID)
+	env.auth.users.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestOIDCService_LinksExistingAccountByEmail(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(nil, gorm.ErrRecordNotFound)
+	env.identities.On("Create", mock.Anything).Return(nil)
+	env.auth.users.On("FindByEmail", "senna@example.com").Return(user, nil)
+
+	_, _, err := env.service.CompleteLogin("test", env.signIn(t, sennaIdentity), ClientInfo{})
+
+	require.NoError(t, err)
+	env.auth.users.AssertNumberOfCalls(t, "Create", 0)
+
+	linked := env.identities.Calls[1].Arguments.Get(0).(*domain.ExternalIdentity)
+	assert.Equal(t, user.ID, linked.UserID)
+}
+
+func TestOIDCService_RefusesToLinkUnverifiedAccount(t *testing.T) {
+	// Someone registered the email with their own password and never
+	// verified it, linking would hand them the account.
+	user := newTestUser(t, "attackerpassword")
+	user.EmailVerifiedAt = nil
+
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(nil, gorm.ErrRecordNotFound)
+	env.auth.users.On("FindByEmail", "senna@example.com").Return(user, nil)
+
+	tokens, _, err := env.service.CompleteLogin("test", env.signIn(t, sennaIdentity), ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrOIDCAccountNotVerified)
+	assert.Nil(t, tokens)
+	assert.False(t, user.IsEmailVerified())
+	env.identities.AssertNotCalled(t, "Create", mock.Anything)
+	env.auth.users.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestOIDCService_RequiresVerifiedEmail(t *testing.T) {
+	identity := sennaIdentity
+	identity.EmailVerified = false
+
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(nil, gorm.ErrRecordNotFound)
+
+	_, _, err := env.service.CompleteLogin("test", env.signIn(t, identity), ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrOIDCEmailNotVerified)
+	env.identities.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestOIDCService_MFAUserGetsChallenge(t *testing.T) {
+	user, _ := newMFAUser(t)
+
+	env := newTestOIDCService(t)
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(&domain.ExternalIdentity{UserID: user.ID}, nil)
+	env.auth.users.On("FindByID", user.ID).Return(user, nil)
+
+	tokens, challenge, err := env.service.CompleteLogin("test", env.signIn(t, sennaIdentity), ClientInfo{})
+
+	require.NoError(t, err)
+	assert.Nil(t, tokens)
+	assert.True(t, challenge.MFARequired)
+}
+
+func TestOIDCService_InvalidState(t *testing.T) {
+	env := newTestOIDCService(t)
+	callback := env.signIn(t, sennaIdentity)
+
+	_, _, err := env.service.CompleteLogin("test", &contract.OIDCCallbackDTO{Code: callback.Code, State: "forged"}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+
+	// States are single use.
+	env.identities.On("FindBySubject", "test", "senna-1988").Return(nil, gorm.ErrRecordNotFound)
+	env.identities.On("Create", mock.Anything).Return(nil)
+	env.auth.users.On("FindByEmail", "senna@example.com").Return(nil, gorm.ErrRecordNotFound)
+	env.auth.users.On("Create", mock.Anything).Return(nil)
+
+	_, _, err = env.service.CompleteLogin("test", callback, ClientInfo{})
+	require.NoError(t, err)
+
+	_, _, err = env.service.CompleteLogin("test", callback, ClientInfo{})
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
+
+func TestOIDCService_ExpiredState(t *testing.T) {
+	env := newTestOIDCService(t)
+	callback := env.signIn(t, sennaIdentity)
+	env.identities.states[pkg.HashSecret(callback.State)].ExpiresAt = time.Now().Add(-time.Second)
+
+	_, _, err := env.service.CompleteLogin("test", callback, ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrInvalidOIDCState)
+}
+
+func TestOIDCService_UnknownProvider(t *testing.T) {
+	env := newTestOIDCService(t)
+
+	_, err := env.service.StartLogin("myspace")
+	assert.ErrorIs(t, err, ErrUnknownOIDCProvider)
+
+	_, _, err = env.service.CompleteLogin("myspace", &contract.OIDCCallbackDTO{Code: "code", State: "state"}, ClientInfo{})
+	assert.ErrorIs(t, err, ErrUnknownOIDCProvider)
+}
+
+func TestOIDCService_ExchangeFailure(t *testing.T) {
+	env := newTestOIDCService(t)
+	callback := env.signIn(t, sennaIdentity)
+	callback.Code = "stolen-code"
+
+	_, _, err := env.service.CompleteLogin("test", callback, ClientInfo{})
+
+	assert.ErrorIs(t, err, ErrOIDCLoginFailed)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/mail"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// ForgotPassword emails a password reset link to email if it belongs to a
+// user. It doesn't report whether it did, so it can't be used to find out
+// which emails are registered.
+func (s *authService) ForgotPassword(email string) error {
+	user, err := s.userRepo.FindByEmail(email)
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resetToken, err := pkg.GenerateSecret()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+
+	err = s.passwordResetRepo.Create(&domain.PasswordResetToken{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		TokenHash: pkg.HashSecret(resetToken),
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.config.PasswordResetTTL),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := s.config.AppURL + "/reset-password?token=" + url.QueryEscape(resetToken)
+
+	return s.mailer.Send(mail.Message{
+		To:      user.Email,
+		Subject: "Reset your ThermoSync password",
+		Body: fmt.Sprintf("Hi %s,\n\nChoose a new password by opening the link below:\n\n%s\n\n"+
+			"The link expires in %s and works once. If you didn't ask to reset your password you can ignore this email.\n",
+			user.FirstName, link, s.config.PasswordResetTTL),
+	})
+}
+
+// ResetPassword sets the password of the user resetDTO.Token was sent to
+// and signs them out everywhere, in case the old password leaked.
+func (s *authService) ResetPassword(resetDTO *contract.ResetPasswordDTO) error {
+	stored, err := s.passwordResetRepo.FindByHash(pkg.HashSecret(resetDTO.Token))
+	if err == gorm.ErrRecordNotFound {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+
+	if stored.UsedAt != nil || !now.Before(stored.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	marked, err := s.passwordResetRepo.MarkUsed(stored.ID, now)
+	if err != nil {
+		return err
+	}
+	if !marked {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.userRepo.FindByID(stored.UserID)
+	if err == gorm.ErrRecordNotFound {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(resetDTO.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	// Following the emailed link proves the user owns the address.
+	if !user.IsEmailVerified() {
+		user.EmailVerifiedAt = &now
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	if err := s.passwordResetRepo.InvalidateByUserID(user.ID, now); err != nil {
+		return err
+	}
+
+	return s.LogoutAll(user.ID)
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type mockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *mockPasswordResetRepository) Create(resetToken *domain.PasswordResetToken) error {
+	args := m.Called(resetToken)
+	return args.Error(0)
+}
+
+func (m *mockPasswordResetRepository) FindByHash(tokenHash string) (*domain.PasswordResetToken, error) {
+	args := m.Called(tokenHash)
+	if resetToken := args.Get(0); resetToken != nil {
+		return resetToken.(*domain.PasswordResetToken), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockPasswordResetRepository) MarkUsed(id uuid.UUID, usedAt time.Time) (bool, error) {
+	args := m.Called(id, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockPasswordResetRepository) InvalidateByUserID(userID uuid.UUID, usedAt time.Time) error {
+	args := m.Called(userID, usedAt)
+	return args.Error(0)
+}
+
+var resetLink = regexp.MustCompile(`/reset-password\?token=(\S+)`)
+
+func TestAuthService_ForgotPassword_SendsHashedToken(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", user.Email).Return(user, nil)
+	mocks.passwordResets.On("Create", mock.Anything).Return(nil)
+
+	require.NoError(t, authService.ForgotPassword(user.Email))
+
+	messages := mocks.mailer.Messages()
+	require.Len(t, messages, 1)
+	assert.Equal(t, user.Email, messages[0].To)
+
+	match := resetLink.FindStringSubmatch(messages[0].Body)
+	require.NotNil(t, match)
+	resetToken, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+
+	stored := mocks.passwordResets.Calls[0].Arguments.Get(0).(*domain.PasswordResetToken)
+	assert.Equal(t, user.ID, stored.UserID)
+	assert.Equal(t, pkg.HashSecret(resetToken), stored.TokenHash)
+	assert.Equal(t, time.Hour, stored.ExpiresAt.Sub(stored.CreatedAt))
+}
+
+func TestAuthService_ForgotPassword_UnknownEmail(t *testing.T) {
+	authService, mocks := newTestAuthService()
+	mocks.users.On("FindByEmail", "nobody@example.com").Return(nil, gorm.ErrRecordNotFound)
+
+	err := authService.ForgotPassword("nobody@example.com")
+
+	assert.NoError(t, err)
+	assert.Empty(t, mocks.mailer.Messages())
+	mocks.passwordResets.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestAuthService_ResetPassword_Success(t *testing.T) {
+	user := newTestUser(t, "supersenha")
+	stored := &domain.PasswordResetToken{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+	session := domain.Session{ID: uuid.New(), UserID: user.ID, ExpiresAt: time.Now().Add(time.Hour)}
+
+	authService, mocks := newTestAuthService()
+	mocks.passwordResets.On("FindByHash", pkg.HashSecret("reset-token")).Return(stored, nil)
+	mocks.passwordResets.On("MarkUsed", stored.ID, mock.Anything).Return(true, nil)
+	mocks.passwordResets.On("InvalidateByUserID", user.ID, mock.Anything).Return(nil)
+	mocks.users.On("FindByID", user.ID).Return(user, nil)
+	mocks.users.On("Update", mock.Anything).Return(nil)
+	mocks.sessions.On("FindActiveByUserID", user.ID, mock.Anything).Return([]domain.Session{session}, nil)
+	mocks.sessions.On("Revoke", session.ID, mock.Anything).Return(nil)
+	mocks.revocations.On("Revoke", session.ID.String(), session.ExpiresAt).Return(nil)
+
+	err := authService.ResetPassword(&contract.ResetPasswordDTO{Token: "reset-token", NewPassword: "novasenha"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("novasenha")))
+	mocks.sessions.AssertCalled(t, "Revoke", session.ID, mock.Anything)
+	mocks.apiTokens.AssertCalled(t, "RevokeAllByUserID", user.ID, mock.Anything)
+	mocks.passwordResets.AssertCalled(t, "InvalidateByUserID", user.ID, mock.Anything)
+}
+
+func TestAuthService_ResetPassword_InvalidToken(t *testing.T) {
+	usedAt := time.Now().Add(-time.Minute)
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		stored *domain.PasswordResetToken
+		err    error
+		marked bool
+	}{
+		{"unknown", nil, gorm.ErrRecordNotFound, false},
+		{"expired", &domain.PasswordResetToken{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(-time.Second)}, nil, false},
+		{"used", &domain.PasswordResetToken{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour), UsedAt: &usedAt}, nil, false},
+		{"used concurrently", &domain.PasswordResetToken{ID: uuid.New(), UserID: userID, ExpiresAt: time.Now().Add(time.Hour)}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newTestAuthService()
+			mocks.passwordResets.On("FindByHash", mock.Anything).Return(tt.stored, tt.err)
+			mocks.passwordResets.On("MarkUsed", mock.Anything, mock.Anything).Return(tt.marked, nil)
+
+			err := authService.ResetPassword(&contract.ResetPasswordDTO{Token: "reset-token", NewPassword: "novasenha"})
+
+			assert.ErrorIs(t, err, ErrInvalidResetToken)
+			mocks.users.AssertNumberOfCalls(t, "Update", 0)
+		})
+	}
+}
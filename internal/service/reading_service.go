@@ -0,0 +1,417 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/repository"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type ReadingService interface {
+	CreateReading(readingDTO *contract.NewReadingDTO) ([]domain.Reading, error)
+	// GetReadingHistory buckets the readings of a device, or of every device
+	// in a room or a home, where each device weighs the same in the
+	// averages.
+	GetReadingHistory(userID uuid.UUID, query *contract.ReadingHistoryQueryDTO) (*contract.ReadingHistoryDTO, error)
+	// GetCurrentReadings returns the latest reading of every device in a
+	// room or a home with the room and whole-house averages.
+	GetCurrentReadings(userID uuid.UUID, query *contract.CurrentReadingsQueryDTO) (*contract.CurrentReadingsDTO, error)
+}
+
+// RoomFinder looks up the rooms a user can see, HomeService implements it.
+type RoomFinder interface {
+	FindRoom(userID, roomID uuid.UUID) (*domain.Room, error)
+	ListRooms(userID, homeID uuid.UUID) ([]domain.Room, error)
+}
+
+// currentReadingMaxAge leaves devices that stopped reporting out of the
+// current readings, so a dead sensor doesn't skew the averages.
+const currentReadingMaxAge = 15 * time.Minute
+
+// maxHistoryBuckets caps how many buckets a single history query may
+// produce, so a one minute bucket can't be asked for over a whole year.
+const maxHistoryBuckets = 2000
+
+var readingBuckets = map[string]time.Duration{
+	"1m": time.Minute,
+	"5m": 5 * time.Minute,
+	"1h": time.Hour,
+	"1d": 24 * time.Hour,
+}
+
+type readingService struct {
+	readingRepo repository.ReadingRepository
+	deviceRepo  repository.DeviceRepository
+	userRepo    repository.UserRepository
+	rooms       RoomFinder
+}
+
+func NewReadingService(readingRepo repository.ReadingRepository, deviceRepo repository.DeviceRepository, userRepo repository.UserRepository, rooms RoomFinder) ReadingService {
+	return &readingService{readingRepo: readingRepo, deviceRepo: deviceRepo, userRepo: userRepo, rooms: rooms}
+}
+
+// CreateReading stores every metric of a reading, returning one
+// domain.Reading per metric. Temperatures are normalised to Celsius, using
+// the device's declared unit when the reading doesn't carry one. Nothing is
+// stored if any metric is unknown or outside its plausible range.
+func (s *readingService) CreateReading(readingDTO *contract.NewReadingDTO) ([]domain.Reading, error) {
+	if err := pkg.ValidateStruct(readingDTO); err != nil {
+		return nil, err
+	}
+
+	device, err := s.deviceRepo.FindByID(readingDTO.DeviceID)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrDeviceNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if device.RetiredAt != nil {
+		return nil, ErrDeviceRetired
+	}
+
+	receivedAt := time.Now().UTC()
+
+	// Sensors without a clock may omit the timestamp, in that case the
+	// moment the server received the reading is the best we have.
+	recordedAt := readingDTO.RecordedAt
+	if recordedAt.IsZero() {
+		recordedAt = receivedAt
+	}
+
+	readings := make([]domain.Reading, 0, len(readingDTO.Metrics))
+	for metric, value := range readingDTO.Metrics {
+		normalized, err := normalizeMetric(metric, value, device)
+		if err != nil {
+			return nil, err
+		}
+
+		readings = append(readings, domain.Reading{
+			ID:         uuid.New(),
+			DeviceID:   device.ID,
+			UserID:     device.UserID,
+			Metric:     metric,
+			Value:      normalized,
+			Unit:       domain.Metrics[metric].Unit,
+			RecordedAt: recordedAt.UTC(),
+			ReceivedAt: receivedAt,
+		})
+	}
+
+	if err := s.readingRepo.Create(readings); err != nil {
+		return nil, err
+	}
+
+	if err := s.deviceRepo.UpdateLastSeen(device.ID, receivedAt); err != nil {
+		return nil, err
+	}
+
+	return readings, nil
+}
+
+func inputErrorf(format string, args ...interface{}) error {
+	return pkg.NewInputError(fmt.Sprintf(format, args...))
+}
+
+// normalizeMetric converts the value to the unit the metric is stored in
+// and checks it is plausible.
+func normalizeMetric(metric string, value contract.MetricValueDTO, device *domain.Device) (float64, error) {
+	spec, ok := domain.Metrics[metric]
+	if !ok {
+		return 0, inputErrorf("unknown metric: %s", metric)
+	}
+
+	normalized := value.Value
+
+	if metric == domain.MetricTemperature {
+		unit := value.Unit
+		if unit == "" {
+			unit = device.TemperatureUnit
+		}
+		if unit == "" {
+			unit = domain.UnitCelsius
+		}
+		if !domain.IsTemperatureUnit(unit) {
+			return 0, inputErrorf("%s unit must be one of: C F K", metric)
+		}
+
+		normalized = domain.ToCelsius(value.Value, unit)
+	} else if value.Unit != "" && value.Unit != spec.Unit {
+		return 0, inputErrorf("%s unit must be %s", metric, spec.Unit)
+	}
+
+	if normalized < spec.Min || normalized > spec.Max {
+		return 0, inputErrorf("%s must be between %g and %g %s", metric, spec.Min, spec.Max, spec.Unit)
+	}
+
+	return normalized, nil
+}
+
+func (s *readingService) GetReadingHistory(userID uuid.UUID, query *contract.ReadingHistoryQueryDTO) (*contract.ReadingHistoryDTO, error) {
+	if err := pkg.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	if countSet(query.DeviceID, query.RoomID, query.HomeID) != 1 {
+		return nil, pkg.NewInputError("exactly one of device_id, room_id or home_id is required")
+	}
+
+	deviceIDs, err := s.historyDevices(userID, query)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := readingBuckets[query.Bucket]
+	from := query.From.UTC()
+	to := query.To.UTC()
+
+	if to.Sub(from)/bucket > maxHistoryBuckets {
+		return nil, pkg.NewInputError("time range too large for bucket size")
+	}
+
+	aggregates, err := s.readingRepo.Aggregate(deviceIDs, query.Metric, from, to, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	unit, convert, err := s.displayUnit(userID, query.Metric)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]contract.ReadingBucketDTO, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		buckets = append(buckets, contract.ReadingBucketDTO{
+			Start: aggregate.BucketStart.UTC(),
+			Min:   convert(aggregate.Min),
+			Max:   convert(aggregate.Max),
+			Avg:   convert(aggregate.Avg),
+			Count: aggregate.Count,
+		})
+	}
+
+	return &contract.ReadingHistoryDTO{
+		DeviceID: optionalID(query.DeviceID),
+		RoomID:   optionalID(query.RoomID),
+		HomeID:   optionalID(query.HomeID),
+		Metric:   query.Metric,
+		Unit:     unit,
+		From:     from,
+		To:       to,
+		Bucket:   query.Bucket,
+		Buckets:  buckets,
+	}, nil
+}
+
+// historyDevices returns the IDs of the devices a history query covers,
+// retired devices included.
+func (s *readingService) historyDevices(userID uuid.UUID, query *contract.ReadingHistoryQueryDTO) ([]uuid.UUID, error) {
+	if query.DeviceID != uuid.Nil {
+		device, err := s.deviceRepo.FindByID(query.DeviceID)
+		if err == gorm.ErrRecordNotFound {
+			return nil, ErrDeviceNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Someone else's device can be seen in a room shared with the user.
+		if device.UserID != userID {
+			if device.RoomID == nil {
+				return nil, ErrDeviceNotFound
+			}
+			if _, err := s.rooms.FindRoom(userID, *device.RoomID); err == ErrRoomNotFound {
+				return nil, ErrDeviceNotFound
+			} else if err != nil {
+				return nil, err
+			}
+		}
+
+		return []uuid.UUID{device.ID}, nil
+	}
+
+	_, devices, err := s.roomDevices(userID, query.RoomID, query.HomeID)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceIDs := make([]uuid.UUID, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+
+	return deviceIDs, nil
+}
+
+// roomDevices returns the room with roomID, or every room of the home with
+// homeID, and the devices assigned to them.
+func (s *readingService) roomDevices(userID, roomID, homeID uuid.UUID) ([]domain.Room, []domain.Device, error) {
+	var rooms []domain.Room
+
+	if roomID != uuid.Nil {
+		room, err := s.rooms.FindRoom(userID, roomID)
+		if err != nil {
+			return nil, nil, err
+		}
+		rooms = []domain.Room{*room}
+	} else {
+		var err error
+		rooms, err = s.rooms.ListRooms(userID, homeID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	roomIDs := make([]uuid.UUID, 0, len(rooms))
+	for _, room := range rooms {
+		roomIDs = append(roomIDs, room.ID)
+	}
+
+	devices, err := s.deviceRepo.FindByRoomIDs(roomIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rooms, devices, nil
+}
+
+func (s *readingService) GetCurrentReadings(userID uuid.UUID, query *contract.CurrentReadingsQueryDTO) (*contract.CurrentReadingsDTO, error) {
+	if err := pkg.ValidateStruct(query); err != nil {
+		return nil, err
+	}
+
+	if countSet(query.RoomID, query.HomeID) != 1 {
+		return nil, pkg.NewInputError("exactly one of room_id or home_id is required")
+	}
+
+	rooms, devices, err := s.roomDevices(userID, query.RoomID, query.HomeID)
+	if err != nil {
+		return nil, err
+	}
+	devices = activeDevices(devices)
+
+	deviceIDs := make([]uuid.UUID, 0, len(devices))
+	for _, device := range devices {
+		deviceIDs = append(deviceIDs, device.ID)
+	}
+
+	readings, err := s.readingRepo.Latest(deviceIDs, query.Metric, time.Now().UTC().Add(-currentReadingMaxAge))
+	if err != nil {
+		return nil, err
+	}
+
+	latest := make(map[uuid.UUID]domain.Reading, len(readings))
+	for _, reading := range readings {
+		latest[reading.DeviceID] = reading
+	}
+
+	unit, convert, err := s.displayUnit(userID, query.Metric)
+	if err != nil {
+		return nil, err
+	}
+
+	current := &contract.CurrentReadingsDTO{
+		HomeID: query.HomeID,
+		Metric: query.Metric,
+		Unit:   unit,
+		Rooms:  make([]contract.RoomReadingsDTO, 0, len(rooms)),
+	}
+	if current.HomeID == uuid.Nil {
+		current.HomeID = rooms[0].HomeID
+	}
+
+	var values []float64
+	for _, room := range rooms {
+		roomReadings := contract.RoomReadingsDTO{
+			RoomID:  room.ID,
+			Name:    room.Name,
+			Devices: []contract.DeviceReadingDTO{},
+		}
+
+		var roomValues []float64
+		for _, device := range devices {
+			reading, ok := latest[device.ID]
+			if !ok || *device.RoomID != room.ID {
+				continue
+			}
+
+			value := convert(reading.Value)
+			roomValues = append(roomValues, value)
+			roomReadings.Devices = append(roomReadings.Devices, contract.DeviceReadingDTO{
+				DeviceID:   device.ID,
+				Name:       device.Name,
+				Value:      value,
+				RecordedAt: reading.RecordedAt.UTC(),
+			})
+		}
+
+		roomReadings.Average = average(roomValues)
+		values = append(values, roomValues...)
+		current.Rooms = append(current.Rooms, roomReadings)
+	}
+
+	current.Average = average(values)
+
+	return current, nil
+}
+
+// displayUnit returns the unit metric is shown in and the conversion from
+// the unit it is stored in. Temperatures are shown in the unit the user
+// prefers.
+func (s *readingService) displayUnit(userID uuid.UUID, metric string) (string, func(float64) float64, error) {
+	unit := domain.Metrics[metric].Unit
+	convert := func(value float64) float64 { return value }
+
+	if metric == domain.MetricTemperature {
+		user, err := s.userRepo.FindByID(userID)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if domain.IsTemperatureUnit(user.TemperatureUnit) {
+			unit = user.TemperatureUnit
+			convert = func(value float64) float64 { return domain.FromCelsius(value, unit) }
+		}
+	}
+
+	return unit, convert, nil
+}
+
+// average returns the mean of values, nil when there are none.
+func average(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+
+	return &mean
+}
+
+func countSet(ids ...uuid.UUID) int {
+	count := 0
+	for _, id := range ids {
+		if id != uuid.Nil {
+			count++
+		}
+	}
+
+	return count
+}
+
+func optionalID(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+
+	return &id
+}
@@ -0,0 +1,596 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type mockReadingRepository struct {
+	mock.Mock
+}
+
+func (m *mockReadingRepository) Create(readings []domain.Reading) error {
+	args := m.Called(readings)
+	return args.Error(0)
+}
+
+func (m *mockReadingRepository) Aggregate(deviceIDs []uuid.UUID, metric string, from, to time.Time, bucket time.Duration) ([]domain.ReadingAggregate, error) {
+	args := m.Called(deviceIDs, metric, from, to, bucket)
+	if aggregates := args.Get(0); aggregates != nil {
+		return aggregates.([]domain.ReadingAggregate), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockReadingRepository) Latest(deviceIDs []uuid.UUID, metric string, since time.Time) ([]domain.Reading, error) {
+	args := m.Called(deviceIDs, metric, since)
+	return args.Get(0).([]domain.Reading), args.Error(1)
+}
+
+func TestReadingService_CreateReading_Success(t *testing.T) {
+	deviceID := uuid.New()
+	userID := uuid.New()
+	recordedAt := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID}, nil)
+	mockDeviceRepo.On("UpdateLastSeen", deviceID, mock.Anything).Return(nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+		DeviceID: deviceID,
+		Metrics: map[string]contract.MetricValueDTO{
+			"temperature": {Value: 22.5, Unit: "C"},
+			"humidity":    {Value: 48},
+		},
+		RecordedAt: recordedAt,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, readings, 2)
+
+	for _, reading := range readings {
+		assert.Equal(t, deviceID, reading.DeviceID)
+		assert.Equal(t, userID, reading.UserID)
+		assert.Equal(t, recordedAt, reading.RecordedAt)
+		assert.False(t, reading.ReceivedAt.IsZero())
+
+		switch reading.Metric {
+		case "temperature":
+			assert.Equal(t, 22.5, reading.Value)
+			assert.Equal(t, "C", reading.Unit)
+		case "humidity":
+			assert.Equal(t, 48.0, reading.Value)
+			assert.Equal(t, "%", reading.Unit)
+		default:
+			t.Errorf("unexpected metric %s", reading.Metric)
+		}
+	}
+
+	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+	mockDeviceRepo.AssertNumberOfCalls(t, "UpdateLastSeen", 1)
+}
+
+func TestReadingService_CreateReading_DefaultsUnitAndRecordedAt(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Create", mock.Anything).Return(nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: uuid.New()}, nil)
+	mockDeviceRepo.On("UpdateLastSeen", deviceID, mock.Anything).Return(nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+		DeviceID: deviceID,
+		Metrics:  map[string]contract.MetricValueDTO{"temperature": {Value: 19}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "C", readings[0].Unit)
+	assert.Equal(t, readings[0].ReceivedAt, readings[0].RecordedAt)
+}
+
+func TestReadingService_CreateReading_Error(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Create", mock.Anything).Return(errors.New("database error"))
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: uuid.New()}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+		DeviceID: deviceID,
+		Metrics:  map[string]contract.MetricValueDTO{"temperature": {Value: 19}},
+	})
+
+	assert.Error(t, err)
+	assert.Nil(t, readings)
+}
+
+func TestReadingService_CreateReading_UnknownDevice(t *testing.T) {
+	deviceID := uuid.New()
+
+	mockRepo := new(mockReadingRepository)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(nil, gorm.ErrRecordNotFound)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+		DeviceID: deviceID,
+		Metrics:  map[string]contract.MetricValueDTO{"temperature": {Value: 19}},
+	})
+
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	assert.Nil(t, readings)
+	mockRepo.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestReadingService_CreateReading_RetiredDevice(t *testing.T) {
+	deviceID := uuid.New()
+	retiredAt := time.Now()
+
+	mockRepo := new(mockReadingRepository)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, RetiredAt: &retiredAt}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	_, err := readingService.CreateReading(&contract.NewReadingDTO{
+		DeviceID: deviceID,
+		Metrics:  map[string]contract.MetricValueDTO{"temperature": {Value: 19}},
+	})
+
+	assert.ErrorIs(t, err, ErrDeviceRetired)
+	mockRepo.AssertNumberOfCalls(t, "Create", 0)
+}
+
+func TestReadingService_CreateReading_NormalizesTemperatureToCelsius(t *testing.T) {
+	tests := []struct {
+		name       string
+		deviceUnit string
+		value      contract.MetricValueDTO
+		celsius    float64
+	}{
+		{"reading unit", "C", contract.MetricValueDTO{Value: 71.6, Unit: "F"}, 22},
+		{"device unit", "F", contract.MetricValueDTO{Value: 71.6}, 22},
+		{"kelvin", "C", contract.MetricValueDTO{Value: 295.15, Unit: "K"}, 22},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceID := uuid.New()
+
+			mockRepo := new(mockReadingRepository)
+			mockRepo.On("Create", mock.Anything).Return(nil)
+
+			mockDeviceRepo := new(mockDeviceRepository)
+			mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, TemperatureUnit: tt.deviceUnit}, nil)
+			mockDeviceRepo.On("UpdateLastSeen", deviceID, mock.Anything).Return(nil)
+
+			readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+			readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+				DeviceID: deviceID,
+				Metrics:  map[string]contract.MetricValueDTO{"temperature": tt.value},
+			})
+
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.celsius, readings[0].Value, 0.001)
+			assert.Equal(t, "C", readings[0].Unit)
+		})
+	}
+}
+
+func TestReadingService_CreateReading_RejectsInvalidMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		metrics map[string]contract.MetricValueDTO
+		err     string
+	}{
+		{"no metrics", map[string]contract.MetricValueDTO{}, "Metrics is required with min: 1"},
+		{"unknown metric", map[string]contract.MetricValueDTO{"radiation": {Value: 1}}, "unknown metric: radiation"},
+		{"wrong unit", map[string]contract.MetricValueDTO{"pressure": {Value: 1013, Unit: "mbar"}}, "pressure unit must be hPa"},
+		{"humidity above range", map[string]contract.MetricValueDTO{"humidity": {Value: 120}}, "humidity must be between 0 and 100 %"},
+		{"co2 below range", map[string]contract.MetricValueDTO{"co2": {Value: -5}}, "co2 must be between 0 and 10000 ppm"},
+		{"implausible temperature", map[string]contract.MetricValueDTO{"temperature": {Value: 300}}, "temperature must be between -50 and 100 C"},
+		{"implausible kelvin", map[string]contract.MetricValueDTO{"temperature": {Value: 20, Unit: "K"}}, "temperature must be between -50 and 100 C"},
+		{"unknown temperature unit", map[string]contract.MetricValueDTO{"temperature": {Value: 20, Unit: "R"}}, "temperature unit must be one of: C F K"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deviceID := uuid.New()
+
+			mockRepo := new(mockReadingRepository)
+
+			mockDeviceRepo := new(mockDeviceRepository)
+			mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, TemperatureUnit: "C"}, nil)
+
+			readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+			readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+				DeviceID: deviceID,
+				Metrics:  tt.metrics,
+			})
+
+			assert.Nil(t, readings)
+			assert.EqualError(t, err, tt.err)
+			mockRepo.AssertNumberOfCalls(t, "Create", 0)
+		})
+	}
+}
+
+func TestReadingService_GetReadingHistory_Success(t *testing.T) {
+	deviceID := uuid.New()
+	userID := uuid.New()
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(2 * time.Hour)
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Aggregate", []uuid.UUID{deviceID}, "temperature", from, to, time.Hour).Return([]domain.ReadingAggregate{
+		{BucketStart: from, Min: 18, Max: 21, Avg: 19.5, Count: 60},
+		{BucketStart: from.Add(time.Hour), Min: 20, Max: 22, Avg: 21, Count: 58},
+	}, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID}, nil)
+
+	mockUserRepo := new(mockUserRepository)
+	mockUserRepo.On("FindByID", userID).Return(&domain.User{ID: userID, TemperatureUnit: "C"}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, mockUserRepo, nil)
+
+	history, err := readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		DeviceID: deviceID,
+		Metric:   "temperature",
+		From:     from,
+		To:       to,
+		Bucket:   "1h",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "1h", history.Bucket)
+	assert.Equal(t, "C", history.Unit)
+	assert.Len(t, history.Buckets, 2)
+	assert.Equal(t, 19.5, history.Buckets[0].Avg)
+	assert.Equal(t, int64(58), history.Buckets[1].Count)
+}
+
+func TestReadingService_GetReadingHistory_InvalidBucket(t *testing.T) {
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+	readingService := NewReadingService(mockRepo, new(mockDeviceRepository), new(mockUserRepository), nil)
+
+	history, err := readingService.GetReadingHistory(uuid.New(), &contract.ReadingHistoryQueryDTO{
+		DeviceID: uuid.New(),
+		Metric:   "temperature",
+		From:     from,
+		To:       from.Add(time.Hour),
+		Bucket:   "2h",
+	})
+
+	assert.Nil(t, history)
+	assert.Equal(t, "Bucket must be one of: 1m 5m 1h 1d", err.Error())
+}
+
+func TestReadingService_GetReadingHistory_ToMustBeAfterFrom(t *testing.T) {
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+	readingService := NewReadingService(mockRepo, new(mockDeviceRepository), new(mockUserRepository), nil)
+
+	_, err := readingService.GetReadingHistory(uuid.New(), &contract.ReadingHistoryQueryDTO{
+		DeviceID: uuid.New(),
+		Metric:   "temperature",
+		From:     from,
+		To:       from.Add(-time.Hour),
+		Bucket:   "1h",
+	})
+
+	assert.Equal(t, "To must be after From", err.Error())
+}
+
+func TestReadingService_GetReadingHistory_RangeTooLarge(t *testing.T) {
+	deviceID := uuid.New()
+	userID := uuid.New()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	_, err := readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		DeviceID: deviceID,
+		Metric:   "temperature",
+		From:     from,
+		To:       from.AddDate(0, 1, 0),
+		Bucket:   "1m",
+	})
+
+	assert.Equal(t, "time range too large for bucket size", err.Error())
+	mockRepo.AssertNumberOfCalls(t, "Aggregate", 0)
+}
+
+func TestReadingService_GetReadingHistory_OtherOwnersDevice(t *testing.T) {
+	deviceID := uuid.New()
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: uuid.New()}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), nil)
+
+	_, err := readingService.GetReadingHistory(uuid.New(), &contract.ReadingHistoryQueryDTO{
+		DeviceID: deviceID,
+		Metric:   "temperature",
+		From:     from,
+		To:       from.Add(time.Hour),
+		Bucket:   "1m",
+	})
+
+	assert.ErrorIs(t, err, ErrDeviceNotFound)
+	mockRepo.AssertNumberOfCalls(t, "Aggregate", 0)
+}
+
+func TestReadingService_GetReadingHistory_ConvertsToPreferredUnit(t *testing.T) {
+	deviceID := uuid.New()
+	userID := uuid.New()
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Aggregate", []uuid.UUID{deviceID}, "temperature", from, to, time.Hour).Return([]domain.ReadingAggregate{
+		{BucketStart: from, Min: 20, Max: 25, Avg: 22, Count: 60},
+	}, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByID", deviceID).Return(&domain.Device{ID: deviceID, UserID: userID}, nil)
+
+	mockUserRepo := new(mockUserRepository)
+	mockUserRepo.On("FindByID", userID).Return(&domain.User{ID: userID, TemperatureUnit: "F"}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, mockUserRepo, nil)
+
+	history, err := readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		DeviceID: deviceID,
+		Metric:   "temperature",
+		From:     from,
+		To:       to,
+		Bucket:   "1h",
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "F", history.Unit)
+	assert.Equal(t, 68.0, history.Buckets[0].Min)
+	assert.Equal(t, 77.0, history.Buckets[0].Max)
+	assert.Equal(t, 71.6, history.Buckets[0].Avg)
+}
+
+type mockRoomFinder struct {
+	mock.Mock
+}
+
+func (m *mockRoomFinder) FindRoom(userID, roomID uuid.UUID) (*domain.Room, error) {
+	args := m.Called(userID, roomID)
+	if room := args.Get(0); room != nil {
+		return room.(*domain.Room), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *mockRoomFinder) ListRooms(userID, homeID uuid.UUID) ([]domain.Room, error) {
+	args := m.Called(userID, homeID)
+	if rooms := args.Get(0); rooms != nil {
+		return rooms.([]domain.Room), args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func TestReadingService_GetReadingHistory_Home(t *testing.T) {
+	userID := uuid.New()
+	homeID := uuid.New()
+	kitchen := domain.Room{ID: uuid.New(), HomeID: homeID, UserID: userID, Name: "Kitchen"}
+	bedroom := domain.Room{ID: uuid.New(), HomeID: homeID, UserID: userID, Name: "Bedroom"}
+	retiredAt := time.Now()
+	devices := []domain.Device{
+		{ID: uuid.New(), UserID: userID, RoomID: &kitchen.ID},
+		{ID: uuid.New(), UserID: userID, RoomID: &bedroom.ID, RetiredAt: &retiredAt},
+	}
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Aggregate", []uuid.UUID{devices[0].ID, devices[1].ID}, "humidity", from, to, time.Hour).Return([]domain.ReadingAggregate{
+		{BucketStart: from, Min: 40, Max: 60, Avg: 50, Count: 120},
+	}, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByRoomIDs", []uuid.UUID{kitchen.ID, bedroom.ID}).Return(devices, nil)
+
+	rooms := new(mockRoomFinder)
+	rooms.On("ListRooms", userID, homeID).Return([]domain.Room{kitchen, bedroom}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, new(mockUserRepository), rooms)
+
+	history, err := readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		HomeID: homeID,
+		Metric: "humidity",
+		From:   from,
+		To:     to,
+		Bucket: "1h",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, homeID, *history.HomeID)
+	assert.Nil(t, history.DeviceID)
+	assert.Equal(t, "%", history.Unit)
+	assert.Equal(t, 50.0, history.Buckets[0].Avg)
+}
+
+func TestReadingService_GetReadingHistory_RequiresOneScope(t *testing.T) {
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		query contract.ReadingHistoryQueryDTO
+	}{
+		{"none", contract.ReadingHistoryQueryDTO{}},
+		{"device and room", contract.ReadingHistoryQueryDTO{DeviceID: uuid.New(), RoomID: uuid.New()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(mockReadingRepository)
+			readingService := NewReadingService(mockRepo, new(mockDeviceRepository), new(mockUserRepository), new(mockRoomFinder))
+
+			query := tt.query
+			query.Metric = "temperature"
+			query.From = from
+			query.To = from.Add(time.Hour)
+			query.Bucket = "1h"
+
+			_, err := readingService.GetReadingHistory(uuid.New(), &query)
+
+			assert.Equal(t, "exactly one of device_id, room_id or home_id is required", err.Error())
+			mockRepo.AssertNumberOfCalls(t, "Aggregate", 0)
+		})
+	}
+}
+
+func TestReadingService_GetReadingHistory_OtherOwnersRoom(t *testing.T) {
+	userID := uuid.New()
+	roomID := uuid.New()
+	from := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	mockRepo := new(mockReadingRepository)
+
+	rooms := new(mockRoomFinder)
+	rooms.On("FindRoom", userID, roomID).Return(nil, ErrRoomNotFound)
+
+	readingService := NewReadingService(mockRepo, new(mockDeviceRepository), new(mockUserRepository), rooms)
+
+	_, err := readingService.GetReadingHistory(userID, &contract.ReadingHistoryQueryDTO{
+		RoomID: roomID,
+		Metric: "temperature",
+		From:   from,
+		To:     from.Add(time.Hour),
+		Bucket: "1h",
+	})
+
+	assert.ErrorIs(t, err, ErrRoomNotFound)
+	mockRepo.AssertNumberOfCalls(t, "Aggregate", 0)
+}
+
+func TestReadingService_GetCurrentReadings_Home(t *testing.T) {
+	userID := uuid.New()
+	homeID := uuid.New()
+	kitchen := domain.Room{ID: uuid.New(), HomeID: homeID, UserID: userID, Name: "Kitchen"}
+	bedroom := domain.Room{ID: uuid.New(), HomeID: homeID, UserID: userID, Name: "Bedroom"}
+	attic := domain.Room{ID: uuid.New(), HomeID: homeID, UserID: userID, Name: "Attic"}
+	retiredAt := time.Now()
+	oven := domain.Device{ID: uuid.New(), Name: "Oven", RoomID: &kitchen.ID}
+	window := domain.Device{ID: uuid.New(), Name: "Window", RoomID: &kitchen.ID}
+	bed := domain.Device{ID: uuid.New(), Name: "Bed", RoomID: &bedroom.ID}
+	old := domain.Device{ID: uuid.New(), Name: "Old", RoomID: &bedroom.ID, RetiredAt: &retiredAt}
+	recordedAt := time.Now().UTC().Truncate(time.Second)
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Latest", []uuid.UUID{oven.ID, window.ID, bed.ID}, "temperature", mock.Anything).Return([]domain.Reading{
+		{DeviceID: oven.ID, Value: 24, RecordedAt: recordedAt},
+		{DeviceID: window.ID, Value: 18, RecordedAt: recordedAt},
+		{DeviceID: bed.ID, Value: 21, RecordedAt: recordedAt},
+	}, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByRoomIDs", []uuid.UUID{kitchen.ID, bedroom.ID, attic.ID}).Return([]domain.Device{oven, window, bed, old}, nil)
+
+	mockUserRepo := new(mockUserRepository)
+	mockUserRepo.On("FindByID", userID).Return(&domain.User{ID: userID, TemperatureUnit: "C"}, nil)
+
+	rooms := new(mockRoomFinder)
+	rooms.On("ListRooms", userID, homeID).Return([]domain.Room{kitchen, bedroom, attic}, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, mockUserRepo, rooms)
+
+	current, err := readingService.GetCurrentReadings(userID, &contract.CurrentReadingsQueryDTO{
+		HomeID: homeID,
+		Metric: "temperature",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, homeID, current.HomeID)
+	assert.Equal(t, "C", current.Unit)
+	assert.Equal(t, 21.0, *current.Average)
+	require.Len(t, current.Rooms, 3)
+
+	assert.Equal(t, "Kitchen", current.Rooms[0].Name)
+	assert.Equal(t, 21.0, *current.Rooms[0].Average)
+	assert.Len(t, current.Rooms[0].Devices, 2)
+
+	assert.Equal(t, 21.0, *current.Rooms[1].Average)
+	assert.Equal(t, []contract.DeviceReadingDTO{{DeviceID: bed.ID, Name: "Bed", Value: 21, RecordedAt: recordedAt}}, current.Rooms[1].Devices)
+
+	assert.Nil(t, current.Rooms[2].Average)
+	assert.Empty(t, current.Rooms[2].Devices)
+}
+
+func TestReadingService_GetCurrentReadings_Room(t *testing.T) {
+	userID := uuid.New()
+	room := &domain.Room{ID: uuid.New(), HomeID: uuid.New(), UserID: userID, Name: "Kitchen"}
+	device := domain.Device{ID: uuid.New(), Name: "Oven", RoomID: &room.ID}
+
+	mockRepo := new(mockReadingRepository)
+	mockRepo.On("Latest", []uuid.UUID{device.ID}, "temperature", mock.Anything).Return([]domain.Reading{
+		{DeviceID: device.ID, Value: 20, RecordedAt: time.Now()},
+	}, nil)
+
+	mockDeviceRepo := new(mockDeviceRepository)
+	mockDeviceRepo.On("FindByRoomIDs", []uuid.UUID{room.ID}).Return([]domain.Device{device}, nil)
+
+	mockUserRepo := new(mockUserRepository)
+	mockUserRepo.On("FindByID", userID).Return(&domain.User{ID: userID, TemperatureUnit: "F"}, nil)
+
+	rooms := new(mockRoomFinder)
+	rooms.On("FindRoom", userID, room.ID).Return(room, nil)
+
+	readingService := NewReadingService(mockRepo, mockDeviceRepo, mockUserRepo, rooms)
+
+	current, err := readingService.GetCurrentReadings(userID, &contract.CurrentReadingsQueryDTO{
+		RoomID: room.ID,
+		Metric: "temperature",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, room.HomeID, current.HomeID)
+	assert.Equal(t, "F", current.Unit)
+	assert.Equal(t, 68.0, *current.Average)
+	assert.Equal(t, 68.0, current.Rooms[0].Devices[0].Value)
+}
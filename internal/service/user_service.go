@@ -2,6 +2,7 @@ package service
 
 import (
 	"errors"
+	"log"
 
 	"github.com/azevedoguigo/thermosync-api/internal/contract"
 	"github.com/azevedoguigo/thermosync-api/internal/domain"
@@ -12,18 +13,53 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrIncorrectPassword = errors.New("current password is incorrect")
+	// ErrPasswordNotSet is returned when confirming an action with the
+	// password of an account that signs in through an identity provider
+	// only, it has to set a password first.
+	ErrPasswordNotSet = errors.New("set a password for the account first")
+	// ErrReauthenticationRequired is returned when setting the first
+	// password of an account from a session that didn't sign in recently.
+	ErrReauthenticationRequired = errors.New("sign in again or give an MFA code to confirm this change")
+)
+
+// AccountGuard confirms sensitive changes to an account and signs the
+// user out elsewhere after them, AuthService implements it.
+type AccountGuard interface {
+	// ConfirmRecentLogin accepts a session that signed in within
+	// AuthConfig.RecentLoginWindow, or a valid MFA code of the user.
+	ConfirmRecentLogin(user *domain.User, sessionID uuid.UUID, code string) error
+	LogoutOthers(userID, keepSessionID uuid.UUID) error
+}
+
 type UserService interface {
 	CreateUser(userDTO *contract.NewUserDTO) error
 	FindUserByID(id uuid.UUID) (*domain.User, error)
-	Login(email, password string) (string, error)
+	UpdatePreferences(id uuid.UUID, preferencesDTO *contract.UserPreferencesDTO) (*domain.User, error)
+	UpdateUser(id uuid.UUID, userDTO *contract.UpdateUserDTO) (*domain.User, error)
+	// ChangePassword keeps the session with sessionID signed in, every
+	// other session and API token of the user is revoked.
+	ChangePassword(id, sessionID uuid.UUID, passwordDTO *contract.ChangePasswordDTO) error
+	DeleteUser(id uuid.UUID, deleteDTO *contract.DeleteUserDTO) error
+}
+
+// UnitNotifier switches the open connections of a user to a new preferred
+// temperature unit, the websocket hub implements it.
+type UnitNotifier interface {
+	SetTemperatureUnit(userID uuid.UUID, unit string)
 }
 
 type userService struct {
 	userRepo repository.UserRepository
+	verifier EmailVerifier
+	guard    AccountGuard
+	units    UnitNotifier
 }
 
-func NewUserService(repo repository.UserRepository) UserService {
-	return &userService{userRepo: repo}
+func NewUserService(repo repository.UserRepository, verifier EmailVerifier, guard AccountGuard, units UnitNotifier) UserService {
+	return &userService{userRepo: repo, verifier: verifier, guard: guard, units: units}
 }
 
 func (s *userService) CreateUser(userDTO *contract.NewUserDTO) error {
@@ -36,7 +72,7 @@ func (s *userService) CreateUser(userDTO *contract.NewUserDTO) error {
 		return err
 	}
 	if user != nil {
-		return errors.New("email already registred")
+		return pkg.NewInputError("email already registred")
 	}
 
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(userDTO.Password), bcrypt.DefaultCost)
@@ -44,12 +80,19 @@ func (s *userService) CreateUser(userDTO *contract.NewUserDTO) error {
 		return err
 	}
 
+	temperatureUnit := userDTO.TemperatureUnit
+	if temperatureUnit == "" {
+		temperatureUnit = domain.UnitCelsius
+	}
+
 	user = &domain.User{
-		ID:        uuid.New(),
-		FirstName: userDTO.FirstName,
-		LastName:  userDTO.LastName,
-		Email:     userDTO.Email,
-		Password:  string(hashedPassword),
+		ID:              uuid.New(),
+		FirstName:       userDTO.FirstName,
+		LastName:        userDTO.LastName,
+		Email:           userDTO.Email,
+		Password:        string(hashedPassword),
+		TemperatureUnit: temperatureUnit,
+		Role:            domain.RoleMember,
 	}
 
 	err = s.userRepo.Create(user)
@@ -57,6 +100,11 @@ func (s *userService) CreateUser(userDTO *contract.NewUserDTO) error {
 		return err
 	}
 
+	// The account exists either way, the user can ask for a new link.
+	if err := s.verifier.SendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to user %s: %s", user.ID, err)
+	}
+
 	return nil
 }
 
@@ -69,16 +117,116 @@ func (s *userService) FindUserByID(id uuid.UUID) (*domain.User, error) {
 	return user, nil
 }
 
-func (s *userService) Login(email string, password string) (string, error) {
-	user, err := s.userRepo.FindByEmail(email)
+// UpdatePreferences backs PUT /users/me/preferences, kept for existing
+// clients. It is UpdateUser with only the preferences given.
+func (s *userService) UpdatePreferences(id uuid.UUID, preferencesDTO *contract.UserPreferencesDTO) (*domain.User, error) {
+	if err := pkg.ValidateStruct(preferencesDTO); err != nil {
+		return nil, err
+	}
+
+	return s.UpdateUser(id, &contract.UpdateUserDTO{TemperatureUnit: &preferencesDTO.TemperatureUnit})
+}
+
+func (s *userService) UpdateUser(id uuid.UUID, userDTO *contract.UpdateUserDTO) (*domain.User, error) {
+	if err := pkg.ValidateStruct(userDTO); err != nil {
+		return nil, err
+	}
+
+	user, err := s.findUser(id)
 	if err != nil {
-		return "", errors.New("email not registred")
+		return nil, err
+	}
+
+	if userDTO.FirstName != nil {
+		user.FirstName = *userDTO.FirstName
+	}
+	if userDTO.LastName != nil {
+		user.LastName = *userDTO.LastName
+	}
+	if userDTO.TemperatureUnit != nil {
+		user.TemperatureUnit = *userDTO.TemperatureUnit
+	}
+
+	if err := s.userRepo.Update(user); err != nil {
+		return nil, err
+	}
+
+	if userDTO.TemperatureUnit != nil {
+		s.units.SetTemperatureUnit(user.ID, user.TemperatureUnit)
+	}
+
+	return user, nil
+}
+
+func (s *userService) ChangePassword(id, sessionID uuid.UUID, passwordDTO *contract.ChangePasswordDTO) error {
+	if err := pkg.ValidateStruct(passwordDTO); err != nil {
+		return err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	user, err := s.findUser(id)
 	if err != nil {
-		return "", errors.New("invalid password")
+		return err
+	}
+
+	// Accounts created through an identity provider have no password to
+	// confirm their first one with, a stolen access token alone must not
+	// be enough to set it.
+	if user.HasPassword() {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(passwordDTO.CurrentPassword)); err != nil {
+			return ErrIncorrectPassword
+		}
+	} else if err := s.guard.ConfirmRecentLogin(user, sessionID, passwordDTO.Code); err != nil {
+		return err
+	}
+	if passwordDTO.NewPassword == passwordDTO.CurrentPassword {
+		return pkg.NewInputError("new password must be different from the current password")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(passwordDTO.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+
+	if err := s.userRepo.Update(user); err != nil {
+		return err
+	}
+
+	return s.guard.LogoutOthers(user.ID, sessionID)
+}
+
+// DeleteUser removes the account together with its devices, readings and
+// sessions once the password is confirmed. Accounts without a password
+// set one with ChangePassword first.
+func (s *userService) DeleteUser(id uuid.UUID, deleteDTO *contract.DeleteUserDTO) error {
+	if err := pkg.ValidateStruct(deleteDTO); err != nil {
+		return err
+	}
+
+	user, err := s.findUser(id)
+	if err != nil {
+		return err
 	}
 
-	return pkg.GenerateJWT(user.ID)
+	if !user.HasPassword() {
+		return ErrPasswordNotSet
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(deleteDTO.Password)); err != nil {
+		return ErrIncorrectPassword
+	}
+
+	return s.userRepo.Delete(user.ID)
+}
+
+func (s *userService) findUser(id uuid.UUID) (*domain.User, error) {
+	user, err := s.userRepo.FindByID(id)
+	if err == gorm.ErrRecordNotFound {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
@@ -10,6 +10,8 @@ import (
 	"github.com/jaswdr/faker"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type mockUserRepository struct {
@@ -21,8 +23,32 @@ func (m *mockUserRepository) Create(user *domain.User) error {
 	return args.Error(0)
 }
 
+func (m *mockUserRepository) Update(user *domain.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+func (m *mockUserRepository) UseTOTPStep(id uuid.UUID, step int64) (bool, error) {
+	args := m.Called(id, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockUserRepository) Delete(id uuid.UUID) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepository) List(email string, offset, limit int) ([]domain.User, int64, error) {
+	args := m.Called(email, offset, limit)
+	return args.Get(0).([]domain.User), args.Get(1).(int64), args.Error(2)
+}
+
 func (m *mockUserRepository) FindByEmail(email string) (*domain.User, error) {
-	panic("unimplemented")
+	args := m.Called(email)
+	if user := args.Get(0); user != nil {
+		return user.(*domain.User), args.Error(1)
+	}
+	return nil, args.Error(1)
 }
 
 func (m *mockUserRepository) FindByID(id uuid.UUID) (*domain.User, error) {
@@ -33,6 +59,37 @@ func (m *mockUserRepository) FindByID(id uuid.UUID) (*domain.User, error) {
 	return nil, args.Error(1)
 }
 
+type mockEmailVerifier struct {
+	mock.Mock
+}
+
+func (m *mockEmailVerifier) SendVerificationEmail(user *domain.User) error {
+	args := m.Called(user)
+	return args.Error(0)
+}
+
+type mockAccountGuard struct {
+	mock.Mock
+}
+
+func (m *mockAccountGuard) ConfirmRecentLogin(user *domain.User, sessionID uuid.UUID, code string) error {
+	args := m.Called(user, sessionID, code)
+	return args.Error(0)
+}
+
+func (m *mockAccountGuard) LogoutOthers(userID, keepSessionID uuid.UUID) error {
+	args := m.Called(userID, keepSessionID)
+	return args.Error(0)
+}
+
+type mockUnitNotifier struct {
+	mock.Mock
+}
+
+func (m *mockUnitNotifier) SetTemperatureUnit(userID uuid.UUID, unit string) {
+	m.Called(userID, unit)
+}
+
 func TestUserService_CreateUser_Success(t *testing.T) {
 	userDTO := &contract.NewUserDTO{
 		FirstName: "Ayrton",
@@ -42,15 +99,20 @@ func TestUserService_CreateUser_Success(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByEmail", userDTO.Email).Return(nil, gorm.ErrRecordNotFound)
 	mockRepo.On("Create", mock.Anything).Return(nil)
 
-	userService := NewUserService(mockRepo)
+	verifier := new(mockEmailVerifier)
+	verifier.On("SendVerificationEmail", mock.Anything).Return(nil)
+
+	userService := NewUserService(mockRepo, verifier, new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
 	assert.NoError(t, err)
 
 	mockRepo.AssertNumberOfCalls(t, "Create", 1)
+	verifier.AssertNumberOfCalls(t, "SendVerificationEmail", 1)
 }
 
 func TestUserService_CreateUser_Error(t *testing.T) {
@@ -62,9 +124,10 @@ func TestUserService_CreateUser_Error(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByEmail", userDTO.Email).Return(nil, gorm.ErrRecordNotFound)
 	mockRepo.On("Create", mock.Anything).Return(errors.New("database error"))
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -82,7 +145,7 @@ func TestUserService_CreateUser_FirstNameIsRequired(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -98,7 +161,7 @@ func TestUserService_CreateUser_MustValidFirstNameMinLenght(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -116,7 +179,7 @@ func TestUserService_CreateUser_MustValidFirstNameMaxLenght(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -132,7 +195,7 @@ func TestUserService_CreateUser_LastNameIsRequired(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -148,7 +211,7 @@ func TestUserService_CreateUser_MustValidLastNameMinLenght(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -166,7 +229,7 @@ func TestUserService_CreateUser_MustValidLastNameMaxLenght(t *testing.T) {
 	}
 
 	mockRepo := new(mockUserRepository)
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -183,7 +246,7 @@ func TestUserService_CreateUser_EmailIsRequired(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -200,7 +263,7 @@ func TestUserService_CreateUser_InvalidEmail(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -219,7 +282,7 @@ func TestUserService_CreateUser_MustValidEmailMaxLength(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -236,7 +299,7 @@ func TestUserService_CreateUser_PasswordIsRequired(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -253,7 +316,7 @@ func TestUserService_CreateUser_MustValidPasswordMinLength(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -272,7 +335,7 @@ func TestUserService_CreateUser_MustValidPasswordMaxLength(t *testing.T) {
 
 	mockRepo := new(mockUserRepository)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	err := userService.CreateUser(userDTO)
 
@@ -293,7 +356,7 @@ func TestUserService_FindUserByID_Success(t *testing.T) {
 	mockRepo := new(mockUserRepository)
 	mockRepo.On("FindByID", userID).Return(user, nil)
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	foundedUser, err := userService.FindUserByID(userID)
 
@@ -312,7 +375,7 @@ func TestUserService_FindUserByID_UserNotFound(t *testing.T) {
 	mockRepo := new(mockUserRepository)
 	mockRepo.On("FindByID", userID).Return(nil, errors.New("User not found"))
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	foundedUser, err := userService.FindUserByID(userID)
 
@@ -327,7 +390,7 @@ func TestUserService_FindByID_InvalidUUIDFormat(t *testing.T) {
 	mockRepo := new(mockUserRepository)
 	mockRepo.On("FindByID", invalidID).Return(nil, errors.New("invalid UUID format"))
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	foundedUser, err := userService.FindUserByID(invalidID)
 
@@ -342,7 +405,7 @@ func TestUserService_FindByID_InvalidUUIDLength(t *testing.T) {
 	mockRepo := new(mockUserRepository)
 	mockRepo.On("FindByID", invalidID).Return(nil, errors.New("invalid UUID length: 25"))
 
-	userService := NewUserService(mockRepo)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
 
 	foundedUser, err := userService.FindUserByID(invalidID)
 
@@ -350,3 +413,200 @@ func TestUserService_FindByID_InvalidUUIDLength(t *testing.T) {
 	assert.Nil(t, foundedUser)
 	assert.Equal(t, "invalid UUID length: 25", err.Error())
 }
+
+func TestUserService_UpdatePreferences_Success(t *testing.T) {
+	userID := uuid.New()
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, TemperatureUnit: "C"}, nil)
+	mockRepo.On("Update", mock.Anything).Return(nil)
+
+	units := new(mockUnitNotifier)
+	units.On("SetTemperatureUnit", userID, "F").Return()
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), units)
+
+	user, err := userService.UpdatePreferences(userID, &contract.UserPreferencesDTO{TemperatureUnit: "F"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "F", user.TemperatureUnit)
+	mockRepo.AssertNumberOfCalls(t, "Update", 1)
+	units.AssertCalled(t, "SetTemperatureUnit", userID, "F")
+}
+
+func TestUserService_UpdatePreferences_InvalidUnit(t *testing.T) {
+	mockRepo := new(mockUserRepository)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	_, err := userService.UpdatePreferences(uuid.New(), &contract.UserPreferencesDTO{TemperatureUnit: "R"})
+
+	assert.Equal(t, "TemperatureUnit must be one of: C F K", err.Error())
+}
+
+func TestUserService_UpdateUser_OnlyChangesGivenFields(t *testing.T) {
+	userID := uuid.New()
+	firstName := "Alain"
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, FirstName: "Ayrton", LastName: "Senna", TemperatureUnit: "C"}, nil)
+	mockRepo.On("Update", mock.Anything).Return(nil)
+
+	units := new(mockUnitNotifier)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), units)
+
+	user, err := userService.UpdateUser(userID, &contract.UpdateUserDTO{FirstName: &firstName})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Alain", user.FirstName)
+	assert.Equal(t, "Senna", user.LastName)
+	assert.Equal(t, "C", user.TemperatureUnit)
+	units.AssertNotCalled(t, "SetTemperatureUnit", mock.Anything, mock.Anything)
+}
+
+func TestUserService_UpdateUser_MustValidFirstNameMinLenght(t *testing.T) {
+	firstName := "A"
+
+	mockRepo := new(mockUserRepository)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	_, err := userService.UpdateUser(uuid.New(), &contract.UpdateUserDTO{FirstName: &firstName})
+
+	assert.Equal(t, "FirstName is required with min: 2", err.Error())
+	mockRepo.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func TestUserService_ChangePassword_Success(t *testing.T) {
+	userID := uuid.New()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("supersenha"), bcrypt.MinCost)
+
+	var updated *domain.User
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, Password: string(hashedPassword)}, nil)
+	mockRepo.On("Update", mock.Anything).Run(func(args mock.Arguments) {
+		updated = args.Get(0).(*domain.User)
+	}).Return(nil)
+
+	sessionID := uuid.New()
+	guard := new(mockAccountGuard)
+	guard.On("LogoutOthers", userID, sessionID).Return(nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), guard, new(mockUnitNotifier))
+
+	err := userService.ChangePassword(userID, sessionID, &contract.ChangePasswordDTO{CurrentPassword: "supersenha", NewPassword: "novasenha"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("novasenha")))
+	guard.AssertCalled(t, "LogoutOthers", userID, sessionID)
+}
+
+func TestUserService_ChangePassword_WrongCurrentPassword(t *testing.T) {
+	userID := uuid.New()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("supersenha"), bcrypt.MinCost)
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, Password: string(hashedPassword)}, nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	err := userService.ChangePassword(userID, uuid.New(), &contract.ChangePasswordDTO{CurrentPassword: "wrong", NewPassword: "novasenha"})
+
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+	mockRepo.AssertNumberOfCalls(t, "Update", 0)
+}
+
+func TestUserService_ChangePassword_SetsFirstPasswordOfPasswordlessAccount(t *testing.T) {
+	userID := uuid.New()
+
+	var updated *domain.User
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID}, nil)
+	mockRepo.On("Update", mock.Anything).Run(func(args mock.Arguments) {
+		updated = args.Get(0).(*domain.User)
+	}).Return(nil)
+
+	sessionID := uuid.New()
+	guard := new(mockAccountGuard)
+	guard.On("ConfirmRecentLogin", mock.Anything, sessionID, "").Return(nil)
+	guard.On("LogoutOthers", userID, sessionID).Return(nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), guard, new(mockUnitNotifier))
+
+	err := userService.ChangePassword(userID, sessionID, &contract.ChangePasswordDTO{NewPassword: "novasenha"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(updated.Password), []byte("novasenha")))
+}
+
+func TestUserService_ChangePassword_FirstPasswordNeedsRecentLogin(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID}, nil)
+
+	guard := new(mockAccountGuard)
+	guard.On("ConfirmRecentLogin", mock.Anything, sessionID, "").Return(ErrReauthenticationRequired)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), guard, new(mockUnitNotifier))
+
+	err := userService.ChangePassword(userID, sessionID, &contract.ChangePasswordDTO{NewPassword: "novasenha"})
+
+	assert.ErrorIs(t, err, ErrReauthenticationRequired)
+	mockRepo.AssertNumberOfCalls(t, "Update", 0)
+	guard.AssertNotCalled(t, "LogoutOthers", mock.Anything, mock.Anything)
+}
+
+func TestUserService_ChangePassword_MustValidNewPasswordMinLength(t *testing.T) {
+	mockRepo := new(mockUserRepository)
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	err := userService.ChangePassword(uuid.New(), uuid.New(), &contract.ChangePasswordDTO{CurrentPassword: "supersenha", NewPassword: "123"})
+
+	assert.Equal(t, "NewPassword is required with min: 6", err.Error())
+}
+
+func TestUserService_DeleteUser_Success(t *testing.T) {
+	userID := uuid.New()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("supersenha"), bcrypt.MinCost)
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, Password: string(hashedPassword)}, nil)
+	mockRepo.On("Delete", userID).Return(nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	err := userService.DeleteUser(userID, &contract.DeleteUserDTO{Password: "supersenha"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "Delete", 1)
+}
+
+func TestUserService_DeleteUser_WrongPassword(t *testing.T) {
+	userID := uuid.New()
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("supersenha"), bcrypt.MinCost)
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID, Password: string(hashedPassword)}, nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	err := userService.DeleteUser(userID, &contract.DeleteUserDTO{Password: "wrong"})
+
+	assert.ErrorIs(t, err, ErrIncorrectPassword)
+	mockRepo.AssertNumberOfCalls(t, "Delete", 0)
+}
+
+func TestUserService_DeleteUser_PasswordlessAccountMustSetPasswordFirst(t *testing.T) {
+	userID := uuid.New()
+
+	mockRepo := new(mockUserRepository)
+	mockRepo.On("FindByID", userID).Return(&domain.User{ID: userID}, nil)
+
+	userService := NewUserService(mockRepo, new(mockEmailVerifier), new(mockAccountGuard), new(mockUnitNotifier))
+
+	err := userService.DeleteUser(userID, &contract.DeleteUserDTO{Password: "supersenha"})
+
+	assert.ErrorIs(t, err, ErrPasswordNotSet)
+	mockRepo.AssertNumberOfCalls(t, "Delete", 0)
+}
@@ -0,0 +1,123 @@
+// Package throttle counts failed attempts per key, e.g. an account or an
+// IP address, and locks keys out after too many of them.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// MaxFailures is how many failures within Window lock a key out, zero
+	// disables the throttle.
+	MaxFailures int
+	Window      time.Duration
+	// Lockout is how long the first lockout lasts, every following one
+	// doubles it up to MaxLockout.
+	Lockout    time.Duration
+	MaxLockout time.Duration
+}
+
+type entry struct {
+	failures    int
+	windowStart time.Time
+	lastFailure time.Time
+	lockouts    int
+	lockedUntil time.Time
+}
+
+// Throttle is safe for concurrent use. Its counters live in memory, so
+// each API instance throttles on its own and restarts clear them.
+type Throttle struct {
+	config    Config
+	mu        sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+func New(config Config) *Throttle {
+	if config.MaxLockout < config.Lockout {
+		config.MaxLockout = config.Lockout
+	}
+
+	return &Throttle{config: config, entries: make(map[string]*entry)}
+}
+
+// RetryAfter returns how long key stays locked out, zero when it isn't.
+func (t *Throttle) RetryAfter(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok || !now.Before(e.lockedUntil) {
+		return 0
+	}
+
+	return e.lockedUntil.Sub(now)
+}
+
+// Fail records a failed attempt for key and reports whether it locked the
+// key out.
+func (t *Throttle) Fail(key string, now time.Time) bool {
+	if t.config.MaxFailures <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune(now)
+
+	e, ok := t.entries[key]
+	if !ok {
+		e = &entry{windowStart: now}
+		t.entries[key] = e
+	}
+
+	if now.Sub(e.windowStart) > t.config.Window {
+		e.failures = 0
+		e.windowStart = now
+	}
+
+	e.failures++
+	e.lastFailure = now
+
+	if e.failures < t.config.MaxFailures {
+		return false
+	}
+
+	lockout := t.config.Lockout << e.lockouts
+	if lockout > t.config.MaxLockout || lockout <= 0 {
+		lockout = t.config.MaxLockout
+	}
+
+	e.failures = 0
+	e.windowStart = now
+	e.lockouts++
+	e.lockedUntil = now.Add(lockout)
+
+	return true
+}
+
+// Reset forgets the failures of key, e.g. after a successful login.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// prune drops keys that failed long enough ago that they'd start over
+// anyway, at most once per window so it stays cheap.
+func (t *Throttle) prune(now time.Time) {
+	if now.Sub(t.lastPrune) < t.config.Window {
+		return
+	}
+	t.lastPrune = now
+
+	for key, e := range t.entries {
+		if now.Sub(e.lastFailure) > t.config.Window && now.After(e.lockedUntil.Add(t.config.MaxLockout)) {
+			delete(t.entries, key)
+		}
+	}
+}
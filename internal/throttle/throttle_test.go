@@ -0,0 +1,72 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testConfig = Config{MaxFailures: 3, Window: time.Minute, Lockout: time.Minute, MaxLockout: 3 * time.Minute}
+
+func TestThrottle_LocksOutAfterMaxFailures(t *testing.T) {
+	throttle := New(testConfig)
+	now := time.Now()
+
+	assert.False(t, throttle.Fail("senna", now))
+	assert.False(t, throttle.Fail("senna", now))
+	assert.Zero(t, throttle.RetryAfter("senna", now))
+
+	assert.True(t, throttle.Fail("senna", now))
+	assert.Equal(t, time.Minute, throttle.RetryAfter("senna", now))
+	assert.Zero(t, throttle.RetryAfter("prost", now))
+
+	assert.Zero(t, throttle.RetryAfter("senna", now.Add(time.Minute)))
+}
+
+func TestThrottle_FailuresOutsideWindowStartOver(t *testing.T) {
+	throttle := New(testConfig)
+	now := time.Now()
+
+	throttle.Fail("senna", now)
+	throttle.Fail("senna", now)
+
+	assert.False(t, throttle.Fail("senna", now.Add(2*time.Minute)))
+	assert.Zero(t, throttle.RetryAfter("senna", now.Add(2*time.Minute)))
+}
+
+func TestThrottle_LockoutsGrowUpToMax(t *testing.T) {
+	throttle := New(testConfig)
+	now := time.Now()
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for _, lockout := range expected {
+		for i := 0; i < testConfig.MaxFailures; i++ {
+			throttle.Fail("senna", now)
+		}
+
+		assert.Equal(t, lockout, throttle.RetryAfter("senna", now))
+		now = now.Add(lockout)
+	}
+}
+
+func TestThrottle_Reset(t *testing.T) {
+	throttle := New(testConfig)
+	now := time.Now()
+
+	throttle.Fail("senna", now)
+	throttle.Fail("senna", now)
+	throttle.Reset("senna")
+
+	assert.False(t, throttle.Fail("senna", now))
+}
+
+func TestThrottle_Disabled(t *testing.T) {
+	throttle := New(Config{})
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		assert.False(t, throttle.Fail("senna", now))
+	}
+	assert.Zero(t, throttle.RetryAfter("senna", now))
+}
@@ -0,0 +1,49 @@
+package token
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// ParsePEMKey reads a PEM encoded RSA or Ed25519 key. A private key
+// (PKCS#8, or PKCS#1 for RSA) can sign, a public key (PKIX) makes a
+// retired key.
+func ParsePEMKey(id string, data []byte) (Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return Key{}, fmt.Errorf("token signing key %s is not PEM encoded", id)
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token signing key %s: %w", id, err)
+		}
+
+		signer, ok := private.(crypto.Signer)
+		if !ok {
+			return Key{}, fmt.Errorf("token signing key %s must be an RSA or Ed25519 key", id)
+		}
+
+		return Key{ID: id, PrivateKey: signer}, nil
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token signing key %s: %w", id, err)
+		}
+
+		return Key{ID: id, PrivateKey: private}, nil
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return Key{}, fmt.Errorf("token signing key %s: %w", id, err)
+		}
+
+		return Key{ID: id, PublicKey: public}, nil
+	}
+
+	return Key{}, fmt.Errorf("token signing key %s has unsupported PEM type %s", id, block.Type)
+}
@@ -0,0 +1,352 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// MinSecretLength is the shortest HMAC secret accepted, shorter secrets
+// can be brute forced from a single token.
+const MinSecretLength = 32
+
+// MinRSAKeyBits is the smallest RSA modulus accepted for RS256 keys.
+const MinRSAKeyBits = 2048
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Key is a signing key identified by the kid header of the tokens it
+// signs. Exactly one of Secret, PrivateKey or PublicKey is set:
+//
+//   - Secret signs HS256 tokens only this service can verify.
+//   - PrivateKey (*rsa.PrivateKey or ed25519.PrivateKey) signs RS256 or
+//     EdDSA tokens, its public half is published in the JWKS.
+//   - PublicKey alone is a retired key: it can no longer sign but tokens
+//     it signed still verify here and downstream until they expire.
+type Key struct {
+	ID         string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+}
+
+type Config struct {
+	Issuer string
+	TTL    time.Duration
+	// SigningKeyID selects the key new tokens are signed with, the other
+	// keys are only used to verify tokens issued before a rotation.
+	// Defaults to the first key.
+	SigningKeyID string
+	Keys         []Key
+	// Leeway tolerates clock drift when checking exp, iat and nbf.
+	Leeway time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Issuer: "thermosync-api",
+		TTL:    15 * time.Minute,
+		Leeway: 30 * time.Second,
+	}
+}
+
+const (
+	// sessionIDClaim names the login session an access token belongs to.
+	sessionIDClaim = "sid"
+	rolesClaim     = "roles"
+)
+
+type Claims struct {
+	// ID is the jti claim, unique per token so it can be revoked.
+	ID        string
+	UserID    uuid.UUID
+	SessionID uuid.UUID
+	Roles     []string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RevocationStore remembers token IDs and session IDs revoked before the
+// tokens carrying them expire.
+type RevocationStore interface {
+	Revoke(id string, expiresAt time.Time) error
+	IsRevoked(ids ...string) (bool, error)
+}
+
+// Service is the only place access tokens are signed and verified.
+type Service interface {
+	// Issue signs an access token for claims.UserID and returns it with
+	// the claims it carries.
+	Issue(claims Claims) (string, *Claims, error)
+	Verify(token string) (*Claims, error)
+	// IssueFor signs a token only accepted by VerifyFor with the same
+	// purpose, e.g. an email verification link, never as an access token.
+	IssueFor(purpose string, claims Claims, ttl time.Duration) (string, *Claims, error)
+	VerifyFor(purpose string, token string) (*Claims, error)
+	// JWKS returns the public keys other services can verify tokens with.
+	JWKS() jwk.Set
+}
+
+type service struct {
+	config     Config
+	signingKey jwk.Key
+	keys       jwk.Set
+	publicKeys jwk.Set
+	now        func() time.Time
+}
+
+func NewService(cfg Config) (Service, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("token issuer is required")
+	}
+	if cfg.TTL <= 0 {
+		return nil, errors.New("token TTL must be positive")
+	}
+	if len(cfg.Keys) == 0 {
+		return nil, errors.New("at least one token signing key is required")
+	}
+
+	s := &service{config: cfg, keys: jwk.NewSet(), publicKeys: jwk.NewSet(), now: time.Now}
+
+	signingKeyID := cfg.SigningKeyID
+	if signingKeyID == "" {
+		signingKeyID = cfg.Keys[0].ID
+	}
+
+	for _, k := range cfg.Keys {
+		if k.ID == "" {
+			return nil, errors.New("token signing keys must have an ID")
+		}
+		if _, ok := s.keys.LookupKeyID(k.ID); ok {
+			return nil, fmt.Errorf("duplicate token signing key %s", k.ID)
+		}
+
+		private, public, err := newJWK(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := s.keys.AddKey(public); err != nil {
+			return nil, err
+		}
+		if _, symmetric := public.(jwk.SymmetricKey); !symmetric {
+			if err := s.publicKeys.AddKey(public); err != nil {
+				return nil, err
+			}
+		}
+
+		if k.ID == signingKeyID {
+			if private == nil {
+				return nil, fmt.Errorf("token signing key %s is retired and cannot sign", k.ID)
+			}
+			s.signingKey = private
+		}
+	}
+
+	if s.signingKey == nil {
+		return nil, fmt.Errorf("unknown token signing key %s", signingKeyID)
+	}
+
+	return s, nil
+}
+
+// newJWK converts k into the key tokens are signed with, nil for retired
+// keys, and the key they are verified with.
+func newJWK(k Key) (jwk.Key, jwk.Key, error) {
+	var raw interface{}
+	var alg jwa.SignatureAlgorithm
+	var err error
+
+	switch {
+	case k.Secret != nil:
+		if len(k.Secret) < MinSecretLength {
+			return nil, nil, fmt.Errorf("token signing key %s must be at least %d bytes", k.ID, MinSecretLength)
+		}
+		raw, alg = k.Secret, jwa.HS256
+	case k.PrivateKey != nil:
+		raw = k.PrivateKey
+		alg, err = algorithmFor(k.ID, k.PrivateKey.Public())
+	case k.PublicKey != nil:
+		raw = k.PublicKey
+		alg, err = algorithmFor(k.ID, k.PublicKey)
+	default:
+		return nil, nil, fmt.Errorf("token signing key %s has no key material", k.ID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := key.Set(jwk.KeyIDKey, k.ID); err != nil {
+		return nil, nil, err
+	}
+	if err := key.Set(jwk.AlgorithmKey, alg); err != nil {
+		return nil, nil, err
+	}
+	if err := key.Set(jwk.KeyUsageKey, jwk.ForSignature); err != nil {
+		return nil, nil, err
+	}
+
+	if alg == jwa.HS256 {
+		return key, key, nil
+	}
+
+	public, err := jwk.PublicKeyOf(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if k.PrivateKey == nil {
+		return nil, public, nil
+	}
+
+	return key, public, nil
+}
+
+func algorithmFor(id string, public crypto.PublicKey) (jwa.SignatureAlgorithm, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < MinRSAKeyBits {
+			return "", fmt.Errorf("token signing key %s must be at least %d bits", id, MinRSAKeyBits)
+		}
+		return jwa.RS256, nil
+	case ed25519.PublicKey:
+		return jwa.EdDSA, nil
+	}
+
+	return "", fmt.Errorf("token signing key %s must be an RSA or Ed25519 key", id)
+}
+
+func (s *service) Issue(claims Claims) (string, *Claims, error) {
+	return s.issue("", claims, s.config.TTL)
+}
+
+func (s *service) IssueFor(purpose string, claims Claims, ttl time.Duration) (string, *Claims, error) {
+	if purpose == "" {
+		return "", nil, errors.New("token purpose is required")
+	}
+
+	return s.issue(purpose, claims, ttl)
+}
+
+// issue signs claims, purpose tokens carry their purpose as audience so
+// they can't be used where another token is expected.
+func (s *service) issue(purpose string, claims Claims, ttl time.Duration) (string, *Claims, error) {
+	now := s.now().Truncate(time.Second)
+
+	claims.ID = uuid.NewString()
+	claims.IssuedAt = now
+	claims.ExpiresAt = now.Add(ttl)
+
+	builder := jwt.NewBuilder().
+		JwtID(claims.ID).
+		Issuer(s.config.Issuer).
+		Subject(claims.UserID.String()).
+		IssuedAt(claims.IssuedAt).
+		Expiration(claims.ExpiresAt)
+	if claims.SessionID != uuid.Nil {
+		builder = builder.Claim(sessionIDClaim, claims.SessionID.String())
+	}
+	if len(claims.Roles) > 0 {
+		builder = builder.Claim(rolesClaim, claims.Roles)
+	}
+	if purpose != "" {
+		builder = builder.Audience([]string{purpose})
+	}
+
+	token, err := builder.Build()
+	if err != nil {
+		return "", nil, err
+	}
+
+	signed, err := jwt.Sign(token, jwt.WithKey(s.signingKey.Algorithm(), s.signingKey))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return string(signed), &claims, nil
+}
+
+func (s *service) Verify(tokenString string) (*Claims, error) {
+	return s.verify("", tokenString)
+}
+
+func (s *service) VerifyFor(purpose string, tokenString string) (*Claims, error) {
+	if purpose == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return s.verify(purpose, tokenString)
+}
+
+func (s *service) verify(purpose string, tokenString string) (*Claims, error) {
+	options := []jwt.ParseOption{
+		jwt.WithKeySet(s.keys),
+		jwt.WithIssuer(s.config.Issuer),
+		jwt.WithClock(jwt.ClockFunc(s.now)),
+		jwt.WithAcceptableSkew(s.config.Leeway),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+		jwt.WithRequiredClaim(jwt.JwtIDKey),
+	}
+	if purpose != "" {
+		options = append(options, jwt.WithAudience(purpose))
+	}
+
+	token, err := jwt.ParseString(tokenString, options...)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if purpose == "" && len(token.Audience()) > 0 {
+		return nil, ErrInvalidToken
+	}
+
+	userID, err := uuid.Parse(token.Subject())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	claims := &Claims{
+		ID:        token.JwtID(),
+		UserID:    userID,
+		IssuedAt:  token.IssuedAt(),
+		ExpiresAt: token.Expiration(),
+	}
+
+	if sid, ok := token.Get(sessionIDClaim); ok {
+		sessionID, err := uuid.Parse(fmt.Sprint(sid))
+		if err != nil {
+			return nil, ErrInvalidToken
+		}
+		claims.SessionID = sessionID
+	}
+
+	if roles, ok := token.Get(rolesClaim); ok {
+		list, ok := roles.([]interface{})
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		for _, role := range list {
+			name, ok := role.(string)
+			if !ok {
+				return nil, ErrInvalidToken
+			}
+			claims.Roles = append(claims.Roles, name)
+		}
+	}
+
+	return claims, nil
+}
+
+func (s *service) JWKS() jwk.Set {
+	return s.publicKeys
+}
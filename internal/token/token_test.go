@@ -0,0 +1,323 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(id string) Key {
+	return Key{ID: id, Secret: []byte(strings.Repeat(id, MinSecretLength))}
+}
+
+var testRSAKey, _ = rsa.GenerateKey(rand.Reader, 2048)
+
+func testEd25519Key(t *testing.T) ed25519.PrivateKey {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	return private
+}
+
+func newTestService(t *testing.T, signingKeyID string, keys ...Key) *service {
+	cfg := DefaultConfig()
+	cfg.SigningKeyID = signingKeyID
+	cfg.Keys = keys
+
+	s, err := NewService(cfg)
+	require.NoError(t, err)
+
+	return s.(*service)
+}
+
+func TestService_IssueAndVerify(t *testing.T) {
+	userID := uuid.New()
+	s := newTestService(t, "", testKey("a"))
+
+	token, _, err := s.Issue(Claims{UserID: userID})
+	require.NoError(t, err)
+
+	claims, err := s.Verify(token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.NotEmpty(t, claims.ID)
+	assert.Equal(t, uuid.Nil, claims.SessionID)
+	assert.WithinDuration(t, claims.IssuedAt.Add(DefaultConfig().TTL), claims.ExpiresAt, time.Second)
+}
+
+func TestService_IssueCarriesSessionRolesAndUniqueID(t *testing.T) {
+	sessionID := uuid.New()
+	s := newTestService(t, "", testKey("a"))
+
+	first, issued, err := s.Issue(Claims{UserID: uuid.New(), SessionID: sessionID, Roles: []string{"admin"}})
+	require.NoError(t, err)
+	_, second, err := s.Issue(Claims{UserID: uuid.New(), SessionID: sessionID})
+	require.NoError(t, err)
+
+	claims, err := s.Verify(first)
+
+	assert.NoError(t, err)
+	assert.Equal(t, sessionID, claims.SessionID)
+	assert.Equal(t, []string{"admin"}, claims.Roles)
+	assert.Equal(t, issued.ID, claims.ID)
+	assert.NotEqual(t, issued.ID, second.ID)
+}
+
+func TestService_VerifyTokensFromRotatedKey(t *testing.T) {
+	userID := uuid.New()
+
+	before := newTestService(t, "a", testKey("a"))
+	token, _, err := before.Issue(Claims{UserID: userID})
+	require.NoError(t, err)
+
+	after := newTestService(t, "b", testKey("a"), testKey("b"))
+
+	claims, err := after.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	newToken, _, err := after.Issue(Claims{UserID: userID})
+	require.NoError(t, err)
+
+	_, err = before.Verify(newToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_VerifyRejectsRemovedKey(t *testing.T) {
+	token, _, err := newTestService(t, "a", testKey("a")).Issue(Claims{UserID: uuid.New()})
+	require.NoError(t, err)
+
+	_, err = newTestService(t, "b", testKey("b")).Verify(token)
+
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_VerifyRejectsSameKidWithOtherSecret(t *testing.T) {
+	token, _, err := newTestService(t, "", testKey("a")).Issue(Claims{UserID: uuid.New()})
+	require.NoError(t, err)
+
+	_, err = newTestService(t, "", Key{ID: "a", Secret: []byte(strings.Repeat("x", MinSecretLength))}).Verify(token)
+
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_VerifyRejectsExpiredToken(t *testing.T) {
+	s := newTestService(t, "", testKey("a"))
+
+	issuedAt := time.Now().Add(-9 * time.Hour)
+	s.now = func() time.Time { return issuedAt }
+
+	token, _, err := s.Issue(Claims{UserID: uuid.New()})
+	require.NoError(t, err)
+
+	s.now = time.Now
+
+	_, err = s.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestService_VerifyRejectsOtherIssuer(t *testing.T) {
+	token, _, err := newTestService(t, "", testKey("a")).Issue(Claims{UserID: uuid.New()})
+	require.NoError(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Issuer = "someone-else"
+	cfg.Keys = []Key{testKey("a")}
+	other, err := NewService(cfg)
+	require.NoError(t, err)
+
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestNewService_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{"no keys", func(c *Config) { c.Keys = nil }, "at least one token signing key is required"},
+		{"short secret", func(c *Config) { c.Keys = []Key{{ID: "a", Secret: []byte("short")}} }, "token signing key a must be at least 32 bytes"},
+		{"missing kid", func(c *Config) { c.Keys = []Key{{Secret: testKey("a").Secret}} }, "token signing keys must have an ID"},
+		{"duplicate kid", func(c *Config) { c.Keys = []Key{testKey("a"), testKey("a")} }, "duplicate token signing key a"},
+		{"unknown signing key", func(c *Config) { c.SigningKeyID = "b" }, "unknown token signing key b"},
+		{"no key material", func(c *Config) { c.Keys = []Key{{ID: "a"}} }, "token signing key a has no key material"},
+		{"retired signing key", func(c *Config) { c.Keys = []Key{{ID: "a", PublicKey: &testRSAKey.PublicKey}} }, "token signing key a is retired and cannot sign"},
+		{"weak rsa key", func(c *Config) {
+			weak, _ := rsa.GenerateKey(rand.Reader, 1024)
+			c.Keys = []Key{{ID: "a", PrivateKey: weak}}
+		}, "token signing key a must be at least 2048 bits"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			cfg.Keys = []Key{testKey("a")}
+			tt.modify(&cfg)
+
+			_, err := NewService(cfg)
+
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestService_AsymmetricKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		key  Key
+		alg  jwa.SignatureAlgorithm
+	}{
+		{"RS256", Key{ID: "rsa", PrivateKey: testRSAKey}, jwa.RS256},
+		{"EdDSA", Key{ID: "ed", PrivateKey: testEd25519Key(t)}, jwa.EdDSA},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := uuid.New()
+			s := newTestService(t, "", tt.key)
+
+			tokenString, _, err := s.Issue(Claims{UserID: userID})
+			require.NoError(t, err)
+
+			claims, err := s.Verify(tokenString)
+			assert.NoError(t, err)
+			assert.Equal(t, userID, claims.UserID)
+
+			// A downstream service only needs the published key set.
+			parsed, err := jwt.ParseString(tokenString, jwt.WithKeySet(s.JWKS()))
+			require.NoError(t, err)
+			assert.Equal(t, userID.String(), parsed.Subject())
+
+			key, ok := s.JWKS().LookupKeyID(tt.key.ID)
+			require.True(t, ok)
+			assert.Equal(t, tt.alg, key.Algorithm())
+		})
+	}
+}
+
+func TestService_RetiredKeyStillVerifiesAndIsPublished(t *testing.T) {
+	userID := uuid.New()
+
+	before := newTestService(t, "", Key{ID: "old", PrivateKey: testRSAKey})
+	tokenString, _, err := before.Issue(Claims{UserID: userID})
+	require.NoError(t, err)
+
+	after := newTestService(t, "new",
+		Key{ID: "new", PrivateKey: testEd25519Key(t)},
+		Key{ID: "old", PublicKey: &testRSAKey.PublicKey},
+	)
+
+	claims, err := after.Verify(tokenString)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	assert.Equal(t, 2, after.JWKS().Len())
+	_, ok := after.JWKS().LookupKeyID("old")
+	assert.True(t, ok)
+}
+
+func TestService_JWKSNeverExposesSecrets(t *testing.T) {
+	s := newTestService(t, "rsa", testKey("hmac"), Key{ID: "rsa", PrivateKey: testRSAKey})
+
+	encoded, err := json.Marshal(s.JWKS())
+	require.NoError(t, err)
+
+	var set struct {
+		Keys []map[string]interface{} `json:"keys"`
+	}
+	require.NoError(t, json.Unmarshal(encoded, &set))
+
+	require.Len(t, set.Keys, 1)
+	assert.Equal(t, "rsa", set.Keys[0]["kid"])
+	assert.Equal(t, "sig", set.Keys[0]["use"])
+	for _, private := range []string{"d", "p", "q", "dp", "dq", "qi", "k"} {
+		assert.NotContains(t, set.Keys[0], private)
+	}
+}
+
+func TestService_VerifyRejectsAlgorithmConfusion(t *testing.T) {
+	s := newTestService(t, "", Key{ID: "rsa", PrivateKey: testRSAKey})
+
+	// HS256 signed with the public key, a classic attack on verifiers
+	// that trust the alg header.
+	publicDER, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	require.NoError(t, err)
+	forgedKey, err := jwk.FromRaw(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	require.NoError(t, err)
+	require.NoError(t, forgedKey.Set(jwk.KeyIDKey, "rsa"))
+
+	forged, err := jwt.NewBuilder().
+		Issuer(DefaultConfig().Issuer).
+		Subject(uuid.NewString()).
+		Expiration(time.Now().Add(time.Hour)).
+		Build()
+	require.NoError(t, err)
+	signed, err := jwt.Sign(forged, jwt.WithKey(jwa.HS256, forgedKey))
+	require.NoError(t, err)
+
+	_, err = s.Verify(string(signed))
+
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestParsePEMKey(t *testing.T) {
+	privateDER, err := x509.MarshalPKCS8PrivateKey(testRSAKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	require.NoError(t, err)
+
+	private, err := ParsePEMKey("current", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	assert.NoError(t, err)
+	assert.Equal(t, "current", private.ID)
+	assert.NotNil(t, private.PrivateKey)
+
+	pkcs1, err := ParsePEMKey("legacy", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testRSAKey)}))
+	assert.NoError(t, err)
+	assert.NotNil(t, pkcs1.PrivateKey)
+
+	public, err := ParsePEMKey("retired", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	assert.NoError(t, err)
+	assert.Nil(t, public.PrivateKey)
+	assert.NotNil(t, public.PublicKey)
+
+	_, err = ParsePEMKey("broken", []byte("not a key"))
+	assert.EqualError(t, err, "token signing key broken is not PEM encoded")
+}
+
+func TestService_PurposeTokensAreNotInterchangeable(t *testing.T) {
+	userID := uuid.New()
+	s := newTestService(t, "", testKey("a"))
+
+	verification, _, err := s.IssueFor("email-verification", Claims{UserID: userID}, time.Hour)
+	require.NoError(t, err)
+	access, _, err := s.Issue(Claims{UserID: userID})
+	require.NoError(t, err)
+
+	claims, err := s.VerifyFor("email-verification", verification)
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+
+	_, err = s.Verify(verification)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = s.VerifyFor("password-reset", verification)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = s.VerifyFor("email-verification", access)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA1 seed of the RFC 6238 test vectors, base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode_RFC6238Vectors(t *testing.T) {
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := Code(rfcSecret, time.Unix(tt.unix, 0))
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.code, code, "at %d", tt.unix)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1234567890, 0)
+
+	step, ok := Validate(rfcSecret, "005924", at)
+	assert.True(t, ok)
+	assert.Equal(t, Step(at), step)
+
+	step, ok = Validate(rfcSecret, "005924", at.Add(Period))
+	assert.True(t, ok, "previous step is accepted")
+	assert.Equal(t, Step(at), step)
+
+	_, ok = Validate(rfcSecret, "005924", at.Add(2*Period))
+	assert.False(t, ok, "older steps are rejected")
+
+	_, ok = Validate(rfcSecret, "000000", at)
+	assert.False(t, ok)
+
+	_, ok = Validate(rfcSecret, "5924", at)
+	assert.False(t, ok)
+
+	_, ok = Validate("not base32!", "005924", at)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+
+	other, err := GenerateSecret()
+	require.NoError(t, err)
+
+	assert.Len(t, secret, 32)
+	assert.NotEqual(t, secret, other)
+
+	code, err := Code(secret, time.Now())
+	require.NoError(t, err)
+
+	_, ok := Validate(secret, code, time.Now())
+	assert.True(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("ThermoSync", "senna@example.com", rfcSecret))
+	require.NoError(t, err)
+
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/ThermoSync:senna@example.com", uri.Path)
+	assert.Equal(t, rfcSecret, uri.Query().Get("secret"))
+	assert.Equal(t, "ThermoSync", uri.Query().Get("issuer"))
+	assert.Equal(t, "6", uri.Query().Get("digits"))
+	assert.Equal(t, "30", uri.Query().Get("period"))
+}
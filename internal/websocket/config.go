@@ -0,0 +1,56 @@
+package websocket
+
+import "time"
+
+type Config struct {
+	// WriteWait is the time allowed to write a message to the peer.
+	WriteWait time.Duration
+	// PongWait is the time allowed to read the next pong, or any other
+	// message, from the peer before the connection is considered dead.
+	PongWait time.Duration
+	// PingPeriod is how often pings are sent, it must be less than PongWait.
+	PingPeriod time.Duration
+	// MaxMessageSize is the maximum size in bytes of a message read from the peer.
+	MaxMessageSize int64
+	// SendBufferSize is how many messages may wait for a client's writer
+	// before the client is considered too slow and disconnected.
+	SendBufferSize int
+	// AllowedOrigins are the browser origins, e.g. https://app.example.com,
+	// allowed to connect besides the API's own. Requests without an Origin
+	// header, such as devices, are always allowed.
+	AllowedOrigins []string
+}
+
+func DefaultConfig() Config {
+	return Config{
+		WriteWait:      10 * time.Second,
+		PongWait:       60 * time.Second,
+		PingPeriod:     54 * time.Second,
+		MaxMessageSize: 4096,
+		SendBufferSize: 64,
+	}
+}
+
+// normalize fills unset values with the defaults and keeps pings frequent
+// enough for the peer to answer before the read deadline.
+func (c Config) normalize() Config {
+	defaults := DefaultConfig()
+
+	if c.WriteWait <= 0 {
+		c.WriteWait = defaults.WriteWait
+	}
+	if c.PongWait <= 0 {
+		c.PongWait = defaults.PongWait
+	}
+	if c.PingPeriod <= 0 || c.PingPeriod >= c.PongWait {
+		c.PingPeriod = c.PongWait * 9 / 10
+	}
+	if c.MaxMessageSize <= 0 {
+		c.MaxMessageSize = defaults.MaxMessageSize
+	}
+	if c.SendBufferSize <= 0 {
+		c.SendBufferSize = defaults.SendBufferSize
+	}
+
+	return c
+}
@@ -1,39 +1,414 @@
 package websocket
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/azevedoguigo/thermosync-api/internal/service"
+	"github.com/azevedoguigo/thermosync-api/pkg"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// Client is a connection registered in the hub, either a user subscribed to
+// the readings of some devices or a device publishing its own. Messages for
+// it are queued on send and written by its own goroutine, so a slow client
+// never holds up the hub.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+
+	// deviceID is only set for device connections.
+	deviceID uuid.UUID
+	// userID is only set for user connections.
+	userID uuid.UUID
+	// deviceIDs are the devices the client is subscribed to, owned by
+	// the hub once the client is registered.
+	deviceIDs []uuid.UUID
+	// temperatureUnit is the unit readings are converted to for the client,
+	// owned by the hub once the client is registered.
+	temperatureUnit string
+	// refresh asks for the room or home view to be pushed again, it is
+	// nil for clients that don't watch one.
+	refresh chan struct{}
+
+	// closeCode is set by the hub before closing send when it drops the
+	// client, and sent to the peer in the close frame.
+	closeCode int
+}
+
+// Authorizer tells whether a user client may subscribe to, or send commands
+// to, a device.
+type Authorizer func(deviceID uuid.UUID) error
+
+type ClientOptions struct {
+	// UserID is the user the client signed in as, changes to their
+	// temperature unit reach the client through Hub.SetTemperatureUnit.
+	UserID uuid.UUID
+	// DeviceIDs are the devices the client starts subscribed to.
+	DeviceIDs []uuid.UUID
+	// TemperatureUnit is the unit the client receives temperatures in.
+	TemperatureUnit string
+	// Authorize decides which devices the client may subscribe to and
+	// send commands to.
+	Authorize Authorizer
+	// AuthorizeCommand, when set, decides which devices the client may
+	// send commands to instead of Authorize, e.g. for read-only users.
+	AuthorizeCommand Authorizer
+	// Current, when set, returns the live view of the room or home the
+	// client watches. It is pushed on connect and after every reading of
+	// the client's devices.
+	Current func() (*contract.CurrentReadingsDTO, error)
+}
+
+// ServeClient serves user clients. They start subscribed to the devices in
+// options and may subscribe to, unsubscribe from and send commands to any
+// device options.Authorize accepts.
+func (h *Hub) ServeClient(w http.ResponseWriter, r *http.Request, options ClientOptions) {
+	client := &Client{userID: options.UserID, deviceIDs: options.DeviceIDs, temperatureUnit: options.TemperatureUnit}
+	if options.Current != nil {
+		client.refresh = make(chan struct{}, 1)
+	}
+
+	if client = h.connect(w, r, client); client == nil {
+		return
+	}
+
+	if options.Current != nil {
+		done := make(chan struct{})
+		defer close(done)
+
+		client.requestRefresh()
+		go client.pushCurrent(options.Current, done)
+	}
+
+	authorize := options.Authorize
+	authorizeCommand := options.AuthorizeCommand
+	if authorizeCommand == nil {
+		authorizeCommand = authorize
+	}
+
+	client.readPump(func(envelope Envelope) {
+		switch envelope.Type {
+		case TypeSubscribe, TypeUnsubscribe:
+			var payload SubscriptionPayload
+			if !client.decode(envelope, &payload) {
+				return
+			}
+
+			subscribe := envelope.Type == TypeSubscribe
+			if subscribe {
+				for _, deviceID := range payload.DeviceIDs {
+					if err := authorize(deviceID); err != nil {
+						client.replyError(envelope.ID, ErrorCodeForbidden, err.Error())
+						return
+					}
+				}
+			}
+
+			h.subscription <- subscription{client: client, deviceIDs: payload.DeviceIDs, subscribe: subscribe}
+			client.ack(envelope.ID)
+		case TypeCommand:
+			var payload CommandPayload
+			if !client.decode(envelope, &payload) {
+				return
+			}
+
+			if err := authorizeCommand(payload.DeviceID); err != nil {
+				client.replyError(envelope.ID, ErrorCodeForbidden, err.Error())
+				return
+			}
+
+			forward, err := NewEnvelope(TypeCommand, envelope.ID, payload)
+			if err != nil {
+				client.replyError(envelope.ID, ErrorCodeInternal, err.Error())
+				return
+			}
+
+			if !h.sendCommand(payload.DeviceID, forward) {
+				client.replyError(envelope.ID, ErrorCodeDeviceOffline, "device is offline")
+				return
+			}
+
+			client.ack(envelope.ID)
+		default:
+			client.replyError(envelope.ID, ErrorCodeUnsupportedType, envelope.Type+" messages can't be sent by clients")
+		}
+	})
+}
+
+// ServeDevice serves an already authenticated device. Readings are
+// attributed to that device whatever device_id the payload carries.
+func (h *Hub) ServeDevice(w http.ResponseWriter, r *http.Request, device *domain.Device, readingService service.ReadingService) {
+	client := h.connect(w, r, &Client{deviceID: device.ID})
+	if client == nil {
+		return
+	}
+
+	client.readPump(func(envelope Envelope) {
+		switch envelope.Type {
+		case TypeReading:
+			var payload ReadingPayload
+			if !client.decode(envelope, &payload) {
+				return
+			}
+
+			readings, err := readingService.CreateReading(&contract.NewReadingDTO{
+				DeviceID:   device.ID,
+				Metrics:    payload.metrics(),
+				RecordedAt: payload.RecordedAt,
+			})
+			var input *pkg.InputError
+			switch {
+			case errors.Is(err, service.ErrDeviceNotFound) || errors.Is(err, service.ErrDeviceRetired):
+				client.replyError(envelope.ID, ErrorCodeForbidden, err.Error())
+				return
+			case errors.As(err, &input):
+				client.replyError(envelope.ID, ErrorCodeInvalidMessage, err.Error())
+				return
+			case err != nil:
+				log.Println("Error to store reading:", err.Error())
+				client.replyError(envelope.ID, ErrorCodeInternal, "internal server error")
+				return
+			}
+
+			h.PublishReading(uuid.NewString(), newReadingPayload(device.ID, readings))
+			client.ack(envelope.ID)
+		case TypeAck, TypeError:
+			// Devices answering a command, nothing waits for it yet.
+		default:
+			client.replyError(envelope.ID, ErrorCodeUnsupportedType, envelope.Type+" messages can't be sent by devices")
+		}
+	})
+}
+
+// newReadingPayload describes stored readings to subscribers. The temperature
+// shorthand is kept for clients that only know about temperature.
+func newReadingPayload(deviceID uuid.UUID, readings []domain.Reading) ReadingPayload {
+	payload := ReadingPayload{
+		DeviceID: deviceID,
+		Metrics:  make(map[string]contract.MetricValueDTO, len(readings)),
+	}
+
+	for _, reading := range readings {
+		payload.Metrics[reading.Metric] = contract.MetricValueDTO{Value: reading.Value, Unit: reading.Unit}
+		payload.RecordedAt = reading.RecordedAt
+
+		if reading.Metric == domain.MetricTemperature {
+			temperature := reading.Value
+			payload.Temperature = &temperature
+			payload.Unit = reading.Unit
+		}
+	}
+
+	return payload
+}
+
+// connect upgrades the request and registers the client with the hub. It
+// returns nil when the upgrade fails, in which case the upgrader has
+// already replied with an HTTP error.
+func (h *Hub) connect(w http.ResponseWriter, r *http.Request, client *Client) *Client {
+	ws, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Websocket upgrade error:", err.Error())
+		return nil
+	}
+
+	client.hub = h
+	client.conn = ws
+	client.send = make(chan []byte, h.config.SendBufferSize)
+	client.closeCode = websocket.CloseNormalClosure
+
+	// The writer drains the queue from the start, registering already
+	// queues a status for every device the client subscribes to.
+	go client.writePump()
+
+	h.register <- client
+
+	return client
 }
 
-var clients = make(map[*websocket.Conn]bool)
+// checkOrigin keeps other sites from opening connections with a visitor's
+// credentials. Browsers always send Origin, so requests without one come
+// from devices and scripts and are let through.
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
 
-func HanldeConnections(w http.ResponseWriter, r *http.Request) {
-	ws, err := upgrader.Upgrade(w, r, nil)
+	u, err := url.Parse(origin)
 	if err != nil {
-		log.Panicln("Websocket uprade error:", err.Error())
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	for _, allowed := range h.config.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// requestRefresh asks for the room or home view to be pushed again without
+// blocking, requests made while one is pending are merged into it.
+func (c *Client) requestRefresh() {
+	if c.refresh == nil {
 		return
 	}
-	defer ws.Close()
 
-	clients[ws] = true
+	select {
+	case c.refresh <- struct{}{}:
+	default:
+	}
+}
 
+// pushCurrent sends the live room or home view whenever a refresh is
+// requested, until done is closed. It runs on its own goroutine since
+// current queries the database.
+func (c *Client) pushCurrent(current func() (*contract.CurrentReadingsDTO, error), done <-chan struct{}) {
 	for {
-		var msg Message
+		select {
+		case <-done:
+			return
+		case <-c.refresh:
+			readings, err := current()
+			if err != nil {
+				log.Println("Error to load current readings:", err.Error())
+				continue
+			}
 
-		err := ws.ReadJSON(&msg)
+			envelope, err := NewEnvelope(TypeCurrentReadings, "", readings)
+			if err != nil {
+				log.Println("Error to encode message:", err.Error())
+				continue
+			}
+
+			c.hub.send(c, envelope)
+		}
+	}
+}
+
+func (c *Client) ack(id string) {
+	envelope, _ := NewEnvelope(TypeAck, id, nil)
+	c.hub.send(c, envelope)
+}
+
+func (c *Client) replyError(id, code, message string) {
+	envelope, _ := NewEnvelope(TypeError, id, ErrorPayload{Code: code, Message: message})
+	c.hub.send(c, envelope)
+}
+
+// decode unmarshals and validates the envelope payload, answering the
+// sender with an error frame when it is not acceptable.
+func (c *Client) decode(envelope Envelope, payload interface{}) bool {
+	if err := json.Unmarshal(envelope.Payload, payload); err != nil {
+		c.replyError(envelope.ID, ErrorCodeInvalidMessage, "invalid payload")
+		return false
+	}
+
+	if err := pkg.ValidateStruct(payload); err != nil {
+		c.replyError(envelope.ID, ErrorCodeInvalidMessage, err.Error())
+		return false
+	}
+
+	return true
+}
+
+// readPump reads until the peer goes away, handing every valid envelope to
+// handle. Pongs and any message push the read deadline forward, so a
+// half-open connection is dropped once PongWait passes without news.
+func (c *Client) readPump(handle func(envelope Envelope)) {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	config := c.hub.config
+
+	c.conn.SetReadLimit(config.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+	})
+
+	for {
+		messageType, payload, err := c.conn.ReadMessage()
 		if err != nil {
-			log.Println("Error to read message:", err)
-			delete(clients, ws)
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+				log.Println("Error to read message:", err)
+			}
+			return
+		}
+
+		c.conn.SetReadDeadline(time.Now().Add(config.PongWait))
+
+		if messageType != websocket.TextMessage {
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseUnsupportedData, "only text messages are supported")
+			c.conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(config.WriteWait))
+			return
+		}
+
+		var envelope Envelope
+		if err := json.Unmarshal(payload, &envelope); err != nil {
+			c.replyError("", ErrorCodeInvalidMessage, "invalid JSON")
+			continue
 		}
 
-		broadcast <- msg
+		if err := pkg.ValidateStruct(&envelope); err != nil {
+			c.replyError(envelope.ID, ErrorCodeInvalidMessage, err.Error())
+			continue
+		}
+
+		handle(envelope)
+	}
+}
+
+// writePump is the only writer of the connection, it drains the send queue
+// and pings the peer every PingPeriod.
+func (c *Client) writePump() {
+	config := c.hub.config
+
+	ticker := time.NewTicker(config.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case payload, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+
+			if !ok {
+				// The hub closed the queue, either on unregister or
+				// because the client fell behind.
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeCode, ""))
+				return
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+				log.Println("Error to send message:", err.Error())
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(config.WriteWait))
+
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
 	}
 }
@@ -0,0 +1,384 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	url    string
+	device *domain.Device
+}
+
+func newTestServer(t *testing.T, config Config) *testServer {
+	t.Helper()
+
+	hub := NewHub(config)
+	go hub.Run()
+
+	device := &domain.Device{ID: uuid.New()}
+
+	authorize := func(deviceID uuid.UUID) error {
+		if deviceID != device.ID {
+			return errors.New("device not found")
+		}
+		return nil
+	}
+
+	router := http.NewServeMux()
+	router.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeClient(w, r, ClientOptions{
+			DeviceIDs:       []uuid.UUID{device.ID},
+			TemperatureUnit: r.URL.Query().Get("unit"),
+			Authorize:       authorize,
+		})
+	})
+	router.HandleFunc("/ws/readonly", func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeClient(w, r, ClientOptions{
+			DeviceIDs: []uuid.UUID{device.ID},
+			Authorize: authorize,
+			AuthorizeCommand: func(deviceID uuid.UUID) error {
+				return errors.New("read-only users can't send commands")
+			},
+		})
+	})
+	var refreshes atomic.Int32
+	router.HandleFunc("/ws/home", func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeClient(w, r, ClientOptions{
+			DeviceIDs: []uuid.UUID{device.ID},
+			Authorize: authorize,
+			Current: func() (*contract.CurrentReadingsDTO, error) {
+				average := float64(refreshes.Add(1))
+				return &contract.CurrentReadingsDTO{Metric: domain.MetricTemperature, Average: &average}, nil
+			},
+		})
+	})
+	router.HandleFunc("/ws/devices", func(w http.ResponseWriter, r *http.Request) {
+		hub.ServeDevice(w, r, device, fakeReadingService{})
+	})
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testServer{url: "ws" + strings.TrimPrefix(server.URL, "http"), device: device}
+}
+
+func (s *testServer) dial(t *testing.T, path string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(s.url+path, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func write(t *testing.T, conn *websocket.Conn, kind, id string, payload interface{}) {
+	t.Helper()
+
+	envelope, err := NewEnvelope(kind, id, payload)
+	require.NoError(t, err)
+	require.NoError(t, conn.WriteJSON(envelope))
+}
+
+// readUntil reads envelopes until one of the given type arrives, skipping
+// anything else such as device status updates.
+func readUntil(t *testing.T, conn *websocket.Conn, kind string) Envelope {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		var envelope Envelope
+		require.NoError(t, conn.ReadJSON(&envelope))
+
+		if envelope.Type == kind {
+			return envelope
+		}
+	}
+}
+
+func readError(t *testing.T, conn *websocket.Conn) (Envelope, ErrorPayload) {
+	t.Helper()
+
+	envelope := readUntil(t, conn, TypeError)
+
+	var payload ErrorPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &payload))
+	return envelope, payload
+}
+
+func TestConnection_DeviceReadingIsAckedAndFannedOut(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+
+	client := server.dial(t, "/ws")
+	status := readUntil(t, client, TypeDeviceStatus)
+	assert.Contains(t, string(status.Payload), DeviceOffline)
+
+	device := server.dial(t, "/ws/devices")
+	status = readUntil(t, client, TypeDeviceStatus)
+	assert.Contains(t, string(status.Payload), DeviceOnline)
+
+	temperature := 21.5
+	write(t, device, TypeReading, "r-1", ReadingPayload{
+		DeviceID:    uuid.New(),
+		Temperature: &temperature,
+		Metrics:     map[string]contract.MetricValueDTO{"humidity": {Value: 45, Unit: "%"}},
+	})
+
+	ack := readUntil(t, device, TypeAck)
+	assert.Equal(t, "r-1", ack.ID)
+
+	envelope := readUntil(t, client, TypeReading)
+	assert.Equal(t, ProtocolVersion, envelope.Version)
+
+	var reading ReadingPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reading))
+	assert.Equal(t, server.device.ID, reading.DeviceID, "the authenticated device wins over the payload")
+	assert.Equal(t, 21.5, *reading.Temperature)
+	assert.Equal(t, 21.5, reading.Metrics["temperature"].Value)
+	assert.Equal(t, 45.0, reading.Metrics["humidity"].Value)
+}
+
+func TestConnection_PushesCurrentReadingsOnConnectAndAfterReadings(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+
+	client := server.dial(t, "/ws/home")
+
+	var current contract.CurrentReadingsDTO
+	require.NoError(t, json.Unmarshal(readUntil(t, client, TypeCurrentReadings).Payload, &current))
+	assert.Equal(t, 1.0, *current.Average)
+
+	device := server.dial(t, "/ws/devices")
+	temperature := 21.5
+	write(t, device, TypeReading, "r-1", ReadingPayload{Temperature: &temperature})
+
+	readUntil(t, client, TypeReading)
+	require.NoError(t, json.Unmarshal(readUntil(t, client, TypeCurrentReadings).Payload, &current))
+	assert.Equal(t, 2.0, *current.Average)
+}
+
+func TestConnection_ReadingIsConvertedToClientUnit(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+
+	celsiusClient := server.dial(t, "/ws")
+	fahrenheitClient := server.dial(t, "/ws?unit=F")
+	readUntil(t, celsiusClient, TypeDeviceStatus)
+	readUntil(t, fahrenheitClient, TypeDeviceStatus)
+
+	device := server.dial(t, "/ws/devices")
+	write(t, device, TypeReading, "r-1", ReadingPayload{Metrics: map[string]contract.MetricValueDTO{
+		"temperature": {Value: 25},
+		"humidity":    {Value: 40},
+	}})
+
+	var reading ReadingPayload
+
+	require.NoError(t, json.Unmarshal(readUntil(t, celsiusClient, TypeReading).Payload, &reading))
+	assert.Equal(t, contract.MetricValueDTO{Value: 25, Unit: "C"}, reading.Metrics["temperature"])
+
+	require.NoError(t, json.Unmarshal(readUntil(t, fahrenheitClient, TypeReading).Payload, &reading))
+	assert.Equal(t, contract.MetricValueDTO{Value: 77, Unit: "F"}, reading.Metrics["temperature"])
+	assert.Equal(t, 77.0, *reading.Temperature)
+	assert.Equal(t, "F", reading.Unit)
+	assert.Equal(t, contract.MetricValueDTO{Value: 40, Unit: "%"}, reading.Metrics["humidity"])
+}
+
+func TestConnection_InvalidJSONGetsErrorFrame(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	device := server.dial(t, "/ws/devices")
+
+	require.NoError(t, device.WriteMessage(websocket.TextMessage, []byte(`not json`)))
+
+	_, payload := readError(t, device)
+	assert.Equal(t, ErrorCodeInvalidMessage, payload.Code)
+}
+
+func TestConnection_UnknownVersionGetsErrorFrame(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	device := server.dial(t, "/ws/devices")
+
+	require.NoError(t, device.WriteJSON(Envelope{Version: 2, Type: TypeReading, ID: "r-1"}))
+
+	envelope, payload := readError(t, device)
+	assert.Equal(t, "r-1", envelope.ID)
+	assert.Equal(t, "Version is invalid.", payload.Message)
+}
+
+func TestConnection_ClientCannotPublishReadings(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	client := server.dial(t, "/ws")
+
+	write(t, client, TypeReading, "r-1", ReadingPayload{DeviceID: server.device.ID, Metrics: map[string]contract.MetricValueDTO{"temperature": {Value: 40}}})
+
+	envelope, payload := readError(t, client)
+	assert.Equal(t, "r-1", envelope.ID)
+	assert.Equal(t, ErrorCodeUnsupportedType, payload.Code)
+}
+
+func TestConnection_SubscribeToForeignDeviceIsForbidden(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	client := server.dial(t, "/ws")
+
+	write(t, client, TypeSubscribe, "s-1", SubscriptionPayload{DeviceIDs: []uuid.UUID{uuid.New()}})
+
+	envelope, payload := readError(t, client)
+	assert.Equal(t, "s-1", envelope.ID)
+	assert.Equal(t, ErrorCodeForbidden, payload.Code)
+}
+
+func TestConnection_SubscribeRequiresDeviceIDs(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	client := server.dial(t, "/ws")
+
+	write(t, client, TypeSubscribe, "s-1", SubscriptionPayload{})
+
+	_, payload := readError(t, client)
+	assert.Equal(t, "DeviceIDs is required", payload.Message)
+}
+
+func TestConnection_CommandIsRoutedToDevice(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	client := server.dial(t, "/ws")
+
+	command := CommandPayload{DeviceID: server.device.ID, Name: "set_interval", Params: json.RawMessage(`{"seconds":30}`)}
+
+	write(t, client, TypeCommand, "c-1", command)
+	_, payload := readError(t, client)
+	assert.Equal(t, ErrorCodeDeviceOffline, payload.Code)
+
+	device := server.dial(t, "/ws/devices")
+	readUntil(t, client, TypeDeviceStatus)
+
+	write(t, client, TypeCommand, "c-2", command)
+	assert.Equal(t, "c-2", readUntil(t, client, TypeAck).ID)
+
+	envelope := readUntil(t, device, TypeCommand)
+	assert.Equal(t, "c-2", envelope.ID)
+	assert.JSONEq(t, `{"device_id":"`+server.device.ID.String()+`","name":"set_interval","params":{"seconds":30}}`, string(envelope.Payload))
+}
+
+func TestConnection_ReadOnlyClientCannotSendCommands(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	client := server.dial(t, "/ws/readonly")
+
+	write(t, client, TypeSubscribe, "s-1", SubscriptionPayload{DeviceIDs: []uuid.UUID{server.device.ID}})
+	assert.Equal(t, "s-1", readUntil(t, client, TypeAck).ID)
+
+	write(t, client, TypeCommand, "c-1", CommandPayload{DeviceID: server.device.ID, Name: "set_interval"})
+
+	envelope, payload := readError(t, client)
+	assert.Equal(t, "c-1", envelope.ID)
+	assert.Equal(t, ErrorCodeForbidden, payload.Code)
+}
+
+func TestConnection_DropsPeerThatStopsAnsweringPings(t *testing.T) {
+	server := newTestServer(t, Config{PongWait: 200 * time.Millisecond, PingPeriod: 50 * time.Millisecond})
+	conn := server.dial(t, "/ws")
+
+	// Pongs are only sent while reading, so not reading simulates a
+	// half-open connection.
+	time.Sleep(400 * time.Millisecond)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var netErr net.Error
+			assert.False(t, errors.As(err, &netErr) && netErr.Timeout(), "server should have closed the connection")
+			return
+		}
+	}
+}
+
+func TestConnection_KeepsPeerThatAnswersPings(t *testing.T) {
+	server := newTestServer(t, Config{PongWait: 200 * time.Millisecond, PingPeriod: 50 * time.Millisecond})
+	conn := server.dial(t, "/ws")
+
+	received := make(chan Envelope, 1)
+	go func() {
+		for {
+			var envelope Envelope
+			if err := conn.ReadJSON(&envelope); err != nil {
+				return
+			}
+			if envelope.Type == TypeReading {
+				received <- envelope
+				return
+			}
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	device := server.dial(t, "/ws/devices")
+	write(t, device, TypeReading, "r-1", ReadingPayload{Metrics: map[string]contract.MetricValueDTO{"temperature": {Value: 20}}})
+
+	select {
+	case envelope := <-received:
+		assert.Contains(t, string(envelope.Payload), `"temperature":20`)
+	case <-time.After(time.Second):
+		t.Fatal("client was disconnected while answering pings")
+	}
+}
+
+func TestConnection_RejectsForeignOrigin(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowedOrigins = []string{"https://app.example.com"}
+	server := newTestServer(t, config)
+
+	_, resp, err := websocket.DefaultDialer.Dial(server.url+"/ws", http.Header{"Origin": {"https://evil.example.com"}})
+
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestConnection_AcceptsAllowedOrigin(t *testing.T) {
+	config := DefaultConfig()
+	config.AllowedOrigins = []string{"https://app.example.com/"}
+	server := newTestServer(t, config)
+
+	conn, _, err := websocket.DefaultDialer.Dial(server.url+"/ws", http.Header{"Origin": {"https://app.example.com"}})
+
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestConnection_ClosesOnMessageTooBig(t *testing.T) {
+	server := newTestServer(t, Config{MaxMessageSize: 64})
+	device := server.dial(t, "/ws/devices")
+
+	write(t, device, TypeReading, "r-1", ReadingPayload{Unit: strings.Repeat("C", 100)})
+
+	_, _, err := device.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseMessageTooBig), "got %v", err)
+}
+
+func TestConnection_ClosesOnBinaryMessage(t *testing.T) {
+	server := newTestServer(t, DefaultConfig())
+	device := server.dial(t, "/ws/devices")
+
+	require.NoError(t, device.WriteMessage(websocket.BinaryMessage, []byte{0x01}))
+
+	for {
+		_, _, err := device.ReadMessage()
+		if err != nil {
+			assert.True(t, websocket.IsCloseError(err, websocket.CloseUnsupportedData), "got %v", err)
+			return
+		}
+	}
+}
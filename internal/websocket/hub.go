@@ -0,0 +1,321 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Hub owns every connected client and their subscriptions. All of its state
+// is only touched by the Run goroutine, connections talk to it through its
+// channels.
+type Hub struct {
+	clients       map[*Client]bool
+	devices       map[uuid.UUID]*Client
+	subscriptions map[uuid.UUID]map[*Client]bool
+
+	register     chan *Client
+	unregister   chan *Client
+	broadcast    chan outbound
+	direct       chan outbound
+	subscription chan subscription
+	commands     chan command
+	disconnect   chan uuid.UUID
+	units        chan unitChange
+
+	config   Config
+	upgrader websocket.Upgrader
+}
+
+// outbound is an envelope for the subscribers of deviceID, or for client
+// alone when it is set. Readings are kept structured instead, so they can
+// be converted to each subscriber's temperature unit.
+type outbound struct {
+	deviceID uuid.UUID
+	client   *Client
+	envelope Envelope
+	reading  *ReadingPayload
+}
+
+type subscription struct {
+	client    *Client
+	deviceIDs []uuid.UUID
+	subscribe bool
+}
+
+// unitChange switches every client of a user to another temperature unit.
+type unitChange struct {
+	userID uuid.UUID
+	unit   string
+}
+
+type command struct {
+	deviceID  uuid.UUID
+	envelope  Envelope
+	delivered chan bool
+}
+
+func NewHub(config Config) *Hub {
+	h := &Hub{
+		clients:       make(map[*Client]bool),
+		devices:       make(map[uuid.UUID]*Client),
+		subscriptions: make(map[uuid.UUID]map[*Client]bool),
+		register:      make(chan *Client),
+		unregister:    make(chan *Client),
+		broadcast:     make(chan outbound, 256),
+		direct:        make(chan outbound, 256),
+		subscription:  make(chan subscription),
+		commands:      make(chan command),
+		disconnect:    make(chan uuid.UUID),
+		units:         make(chan unitChange),
+		config:        config.normalize(),
+	}
+	h.upgrader = websocket.Upgrader{CheckOrigin: h.checkOrigin}
+
+	return h
+}
+
+func (h *Hub) Run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.add(client)
+		case client := <-h.unregister:
+			h.remove(client)
+		case msg := <-h.broadcast:
+			if msg.reading != nil {
+				h.fanOutReading(msg.envelope.ID, *msg.reading)
+			} else {
+				h.fanOut(msg.deviceID, msg.envelope)
+			}
+		case msg := <-h.direct:
+			if h.clients[msg.client] {
+				h.deliver(msg.client, msg.envelope)
+			}
+		case sub := <-h.subscription:
+			// A client evicted as too slow may still have a request in
+			// flight, its send queue is closed already.
+			if !h.clients[sub.client] {
+				continue
+			}
+			if sub.subscribe {
+				h.subscribe(sub.client, sub.deviceIDs)
+			} else {
+				h.unsubscribe(sub.client, sub.deviceIDs)
+			}
+		case cmd := <-h.commands:
+			device, online := h.devices[cmd.deviceID]
+			if online {
+				h.deliver(device, cmd.envelope)
+			}
+			cmd.delivered <- online
+		case deviceID := <-h.disconnect:
+			if device, online := h.devices[deviceID]; online {
+				device.closeCode = websocket.ClosePolicyViolation
+				h.remove(device)
+			}
+		case change := <-h.units:
+			for client := range h.clients {
+				if client.userID == change.userID {
+					client.temperatureUnit = change.unit
+					client.requestRefresh()
+				}
+			}
+		}
+	}
+}
+
+// Publish queues an envelope to be delivered to the subscribers of a device.
+func (h *Hub) Publish(deviceID uuid.UUID, envelope Envelope) {
+	h.broadcast <- outbound{deviceID: deviceID, envelope: envelope}
+}
+
+// PublishReading queues a reading for the subscribers of its device, each
+// one gets the temperature in the unit it asked for.
+func (h *Hub) PublishReading(id string, reading ReadingPayload) {
+	h.broadcast <- outbound{deviceID: reading.DeviceID, envelope: Envelope{ID: id}, reading: &reading}
+}
+
+// DisconnectDevice closes the connection of the device, e.g. once it is
+// retired or its secret rotated, it has to authenticate again.
+func (h *Hub) DisconnectDevice(deviceID uuid.UUID) {
+	h.disconnect <- deviceID
+}
+
+// SetTemperatureUnit switches the open connections of a user to unit, the
+// readings and live views they get from then on are converted to it.
+func (h *Hub) SetTemperatureUnit(userID uuid.UUID, unit string) {
+	h.units <- unitChange{userID: userID, unit: unit}
+}
+
+// send queues an envelope for a single client, it is dropped if the client
+// has left in the meantime.
+func (h *Hub) send(client *Client, envelope Envelope) {
+	h.direct <- outbound{client: client, envelope: envelope}
+}
+
+// sendCommand forwards a command to a connected device and reports whether
+// the device was online to receive it.
+func (h *Hub) sendCommand(deviceID uuid.UUID, envelope Envelope) bool {
+	delivered := make(chan bool, 1)
+	h.commands <- command{deviceID: deviceID, envelope: envelope, delivered: delivered}
+	return <-delivered
+}
+
+func (h *Hub) add(client *Client) {
+	h.clients[client] = true
+
+	if client.deviceID != uuid.Nil {
+		h.devices[client.deviceID] = client
+		h.announce(client.deviceID, DeviceOnline)
+	}
+
+	h.subscribe(client, client.deviceIDs)
+}
+
+// remove forgets the client and closes its send queue, which makes its
+// writer close the connection. It is a no-op for clients already removed.
+func (h *Hub) remove(client *Client) {
+	if !h.clients[client] {
+		return
+	}
+
+	h.unsubscribe(client, client.deviceIDs)
+
+	if client.deviceID != uuid.Nil && h.devices[client.deviceID] == client {
+		delete(h.devices, client.deviceID)
+		h.announce(client.deviceID, DeviceOffline)
+	}
+
+	delete(h.clients, client)
+	close(client.send)
+}
+
+func (h *Hub) subscribe(client *Client, deviceIDs []uuid.UUID) {
+	for _, deviceID := range deviceIDs {
+		if h.subscriptions[deviceID] == nil {
+			h.subscriptions[deviceID] = make(map[*Client]bool)
+		}
+		if h.subscriptions[deviceID][client] {
+			continue
+		}
+
+		h.subscriptions[deviceID][client] = true
+		client.deviceIDs = appendUnique(client.deviceIDs, deviceID)
+
+		// Let the new subscriber know straight away whether the device
+		// is connected, it would otherwise only learn on the next change.
+		status := DeviceOffline
+		if _, online := h.devices[deviceID]; online {
+			status = DeviceOnline
+		}
+		h.deliver(client, deviceStatusEnvelope(deviceID, status))
+
+		// The statuses may have filled the queue and dropped the client,
+		// which also dropped the subscriptions made so far.
+		if !h.clients[client] {
+			return
+		}
+	}
+}
+
+func (h *Hub) unsubscribe(client *Client, deviceIDs []uuid.UUID) {
+	for _, deviceID := range append([]uuid.UUID(nil), deviceIDs...) {
+		delete(h.subscriptions[deviceID], client)
+		if len(h.subscriptions[deviceID]) == 0 {
+			delete(h.subscriptions, deviceID)
+		}
+
+		client.deviceIDs = removeID(client.deviceIDs, deviceID)
+	}
+}
+
+func (h *Hub) announce(deviceID uuid.UUID, status string) {
+	h.fanOut(deviceID, deviceStatusEnvelope(deviceID, status))
+}
+
+func (h *Hub) fanOut(deviceID uuid.UUID, envelope Envelope) {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Println("Error to encode message:", err.Error())
+		return
+	}
+
+	for client := range h.subscriptions[deviceID] {
+		h.enqueue(client, payload)
+	}
+}
+
+func (h *Hub) fanOutReading(id string, reading ReadingPayload) {
+	encoded := make(map[string][]byte)
+
+	for client := range h.subscriptions[reading.DeviceID] {
+		payload, ok := encoded[client.temperatureUnit]
+		if !ok {
+			envelope, err := NewEnvelope(TypeReading, id, reading.inUnit(client.temperatureUnit))
+			if err == nil {
+				payload, err = json.Marshal(envelope)
+			}
+			if err != nil {
+				log.Println("Error to encode message:", err.Error())
+				return
+			}
+
+			encoded[client.temperatureUnit] = payload
+		}
+
+		h.enqueue(client, payload)
+		client.requestRefresh()
+	}
+}
+
+func (h *Hub) deliver(client *Client, envelope Envelope) {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		log.Println("Error to encode message:", err.Error())
+		return
+	}
+
+	h.enqueue(client, payload)
+}
+
+// enqueue never blocks the hub, a client whose queue is full is dropped.
+// Clients already dropped are skipped, their queue is closed.
+func (h *Hub) enqueue(client *Client, payload []byte) {
+	if !h.clients[client] {
+		return
+	}
+
+	select {
+	case client.send <- payload:
+	default:
+		log.Println("Websocket client too slow, disconnecting")
+		client.closeCode = websocket.CloseTryAgainLater
+		h.remove(client)
+	}
+}
+
+func deviceStatusEnvelope(deviceID uuid.UUID, status string) Envelope {
+	envelope, _ := NewEnvelope(TypeDeviceStatus, "", DeviceStatusPayload{DeviceID: deviceID, Status: status})
+	return envelope
+}
+
+func appendUnique(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+func removeID(ids []uuid.UUID, id uuid.UUID) []uuid.UUID {
+	for i, existing := range ids {
+		if existing == id {
+			return append(ids[:i], ids[i+1:]...)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,333 @@
+package websocket
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReadingService struct{}
+
+func (fakeReadingService) CreateReading(readingDTO *contract.NewReadingDTO) ([]domain.Reading, error) {
+	var readings []domain.Reading
+
+	for metric, value := range readingDTO.Metrics {
+		readings = append(readings, domain.Reading{
+			ID:         uuid.New(),
+			DeviceID:   readingDTO.DeviceID,
+			Metric:     metric,
+			Value:      value.Value,
+			Unit:       domain.Metrics[metric].Unit,
+			RecordedAt: time.Now().UTC(),
+		})
+	}
+
+	return readings, nil
+}
+
+func (fakeReadingService) GetReadingHistory(userID uuid.UUID, query *contract.ReadingHistoryQueryDTO) (*contract.ReadingHistoryDTO, error) {
+	return nil, nil
+}
+
+func (fakeReadingService) GetCurrentReadings(userID uuid.UUID, query *contract.CurrentReadingsQueryDTO) (*contract.CurrentReadingsDTO, error) {
+	return nil, nil
+}
+
+func newTestClient(hub *Hub, bufferSize int, deviceIDs ...uuid.UUID) *Client {
+	client := &Client{hub: hub, send: make(chan []byte, bufferSize), deviceIDs: deviceIDs}
+	hub.register <- client
+	return client
+}
+
+func newTestDevice(hub *Hub, deviceID uuid.UUID) *Client {
+	client := &Client{hub: hub, send: make(chan []byte, 8), deviceID: deviceID}
+	hub.register <- client
+	return client
+}
+
+func readingEnvelope(t *testing.T, deviceID uuid.UUID, temperature float64) Envelope {
+	t.Helper()
+
+	envelope, err := NewEnvelope(TypeReading, uuid.NewString(), ReadingPayload{DeviceID: deviceID, Temperature: &temperature})
+	require.NoError(t, err)
+	return envelope
+}
+
+func receive(t *testing.T, client *Client) (Envelope, bool) {
+	t.Helper()
+
+	select {
+	case payload, ok := <-client.send:
+		if !ok {
+			return Envelope{}, false
+		}
+
+		var envelope Envelope
+		require.NoError(t, json.Unmarshal(payload, &envelope))
+		return envelope, true
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for message")
+		return Envelope{}, false
+	}
+}
+
+func receiveStatus(t *testing.T, client *Client) DeviceStatusPayload {
+	t.Helper()
+
+	envelope, ok := receive(t, client)
+	require.True(t, ok)
+	require.Equal(t, TypeDeviceStatus, envelope.Type)
+
+	var status DeviceStatusPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &status))
+	return status
+}
+
+func TestHub_DeliversOnlyToSubscribers(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	kitchen := uuid.New()
+	bedroom := uuid.New()
+
+	kitchenClient := newTestClient(hub, 4, kitchen)
+	bedroomClient := newTestClient(hub, 4, bedroom)
+
+	assert.Equal(t, kitchen, receiveStatus(t, kitchenClient).DeviceID)
+	assert.Equal(t, bedroom, receiveStatus(t, bedroomClient).DeviceID)
+
+	hub.Publish(kitchen, readingEnvelope(t, kitchen, 23))
+	hub.Publish(bedroom, readingEnvelope(t, bedroom, 19))
+
+	envelope, ok := receive(t, kitchenClient)
+	assert.True(t, ok)
+	assert.Equal(t, TypeReading, envelope.Type)
+
+	var reading ReadingPayload
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reading))
+	assert.Equal(t, kitchen, reading.DeviceID)
+	assert.Equal(t, 23.0, *reading.Temperature)
+
+	envelope, ok = receive(t, bedroomClient)
+	assert.True(t, ok)
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reading))
+	assert.Equal(t, bedroom, reading.DeviceID)
+
+	assert.Len(t, kitchenClient.send, 0)
+	assert.Len(t, bedroomClient.send, 0)
+}
+
+func TestHub_EvictsSlowClient(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+
+	slow := newTestClient(hub, 2, deviceID)
+	fast := newTestClient(hub, 8, deviceID)
+
+	for i := 0; i < 3; i++ {
+		hub.Publish(deviceID, readingEnvelope(t, deviceID, float64(i)))
+	}
+
+	receiveStatus(t, fast)
+	for i := 0; i < 3; i++ {
+		_, ok := receive(t, fast)
+		assert.True(t, ok)
+	}
+
+	receiveStatus(t, slow)
+	_, ok := receive(t, slow)
+	assert.True(t, ok, "the buffered message is still delivered")
+
+	_, ok = receive(t, slow)
+	assert.False(t, ok, "the send queue is closed once the client falls behind")
+}
+
+func TestHub_UnregisterClosesQueue(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	client := newTestClient(hub, 4, deviceID)
+
+	hub.unregister <- client
+	hub.unregister <- client
+
+	receiveStatus(t, client)
+	_, ok := receive(t, client)
+	assert.False(t, ok)
+}
+
+func TestHub_AnnouncesDeviceStatus(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	client := newTestClient(hub, 4, deviceID)
+	assert.Equal(t, DeviceOffline, receiveStatus(t, client).Status)
+
+	device := newTestDevice(hub, deviceID)
+	assert.Equal(t, DeviceOnline, receiveStatus(t, client).Status)
+
+	hub.unregister <- device
+	assert.Equal(t, DeviceOffline, receiveStatus(t, client).Status)
+}
+
+func TestHub_IgnoresSubscriptionsOfEvictedClient(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	slow := newTestClient(hub, 1, deviceID)
+
+	hub.Publish(deviceID, readingEnvelope(t, deviceID, 21))
+
+	// Without the check this would write to the closed send queue and
+	// panic the hub.
+	other := uuid.New()
+	hub.subscription <- subscription{client: slow, deviceIDs: []uuid.UUID{other}, subscribe: true}
+	hub.subscription <- subscription{client: slow, deviceIDs: []uuid.UUID{deviceID}, subscribe: false}
+	hub.sendCommand(uuid.New(), Envelope{})
+
+	receiveStatus(t, slow)
+	_, ok := receive(t, slow)
+	assert.False(t, ok)
+	assert.Empty(t, hub.subscriptions[other])
+}
+
+func TestHub_DropsClientWithMoreDevicesThanItsQueueHolds(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceIDs := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+	client := newTestClient(hub, 2, deviceIDs...)
+
+	// Subscribing on register queues a status per device, the hub must
+	// stop at the first one that doesn't fit instead of panicking.
+	hub.sendCommand(uuid.New(), Envelope{})
+
+	receiveStatus(t, client)
+	receiveStatus(t, client)
+	_, ok := receive(t, client)
+	assert.False(t, ok)
+	assert.Empty(t, hub.subscriptions)
+}
+
+func TestHub_DropsClientSubscribingToMoreDevicesThanItsQueueHolds(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	client := newTestClient(hub, 1)
+
+	hub.subscription <- subscription{client: client, deviceIDs: []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}, subscribe: true}
+	hub.sendCommand(uuid.New(), Envelope{})
+
+	receiveStatus(t, client)
+	_, ok := receive(t, client)
+	assert.False(t, ok)
+	assert.Empty(t, hub.subscriptions)
+}
+
+func TestHub_DisconnectDevice(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	client := newTestClient(hub, 4, deviceID)
+	receiveStatus(t, client)
+
+	device := newTestDevice(hub, deviceID)
+	assert.Equal(t, DeviceOnline, receiveStatus(t, client).Status)
+
+	hub.DisconnectDevice(deviceID)
+	hub.DisconnectDevice(uuid.New())
+
+	_, ok := receive(t, device)
+	assert.False(t, ok)
+	assert.Equal(t, websocket.ClosePolicyViolation, device.closeCode)
+	assert.Equal(t, DeviceOffline, receiveStatus(t, client).Status)
+
+	assert.False(t, hub.sendCommand(deviceID, Envelope{}))
+}
+
+func TestHub_SubscribeAndUnsubscribe(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	client := newTestClient(hub, 4)
+
+	hub.subscription <- subscription{client: client, deviceIDs: []uuid.UUID{deviceID}, subscribe: true}
+	receiveStatus(t, client)
+
+	hub.Publish(deviceID, readingEnvelope(t, deviceID, 21))
+	envelope, _ := receive(t, client)
+	assert.Equal(t, TypeReading, envelope.Type)
+
+	hub.subscription <- subscription{client: client, deviceIDs: []uuid.UUID{deviceID}, subscribe: false}
+	hub.Publish(deviceID, readingEnvelope(t, deviceID, 22))
+
+	// The hub handles channels one at a time, so once the command below is
+	// answered the reading above has been fanned out too.
+	hub.sendCommand(uuid.New(), Envelope{})
+	assert.Len(t, client.send, 0)
+}
+
+func TestHub_SendCommand(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	command, err := NewEnvelope(TypeCommand, "cmd-1", CommandPayload{DeviceID: deviceID, Name: "set_interval"})
+	require.NoError(t, err)
+
+	assert.False(t, hub.sendCommand(deviceID, command))
+
+	device := newTestDevice(hub, deviceID)
+	assert.True(t, hub.sendCommand(deviceID, command))
+
+	envelope, ok := receive(t, device)
+	assert.True(t, ok)
+	assert.Equal(t, TypeCommand, envelope.Type)
+	assert.Equal(t, "cmd-1", envelope.ID)
+}
+
+func TestHub_SetTemperatureUnit(t *testing.T) {
+	hub := NewHub(DefaultConfig())
+	go hub.Run()
+
+	deviceID := uuid.New()
+	userID := uuid.New()
+
+	client := &Client{hub: hub, send: make(chan []byte, 4), userID: userID, deviceIDs: []uuid.UUID{deviceID}, temperatureUnit: domain.UnitCelsius}
+	hub.register <- client
+	other := newTestClient(hub, 4, deviceID)
+
+	receiveStatus(t, client)
+	receiveStatus(t, other)
+
+	hub.SetTemperatureUnit(userID, domain.UnitFahrenheit)
+
+	temperature := 25.0
+	hub.PublishReading("r-1", ReadingPayload{DeviceID: deviceID, Temperature: &temperature})
+
+	var reading ReadingPayload
+
+	envelope, ok := receive(t, client)
+	require.True(t, ok)
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reading))
+	assert.Equal(t, 77.0, *reading.Temperature)
+
+	envelope, ok = receive(t, other)
+	require.True(t, ok)
+	require.NoError(t, json.Unmarshal(envelope.Payload, &reading))
+	assert.Equal(t, 25.0, *reading.Temperature)
+}
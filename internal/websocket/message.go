@@ -1,5 +1,142 @@
 package websocket
 
-type Message struct {
-	Temperature float64 `json:"temperature"`
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/azevedoguigo/thermosync-api/internal/contract"
+	"github.com/azevedoguigo/thermosync-api/internal/domain"
+	"github.com/google/uuid"
+)
+
+// ProtocolVersion is the envelope version spoken by the server, frames
+// carrying any other version are rejected.
+const ProtocolVersion = 1
+
+const (
+	TypeReading      = "reading"
+	TypeSubscribe    = "subscribe"
+	TypeUnsubscribe  = "unsubscribe"
+	TypeAck          = "ack"
+	TypeError        = "error"
+	TypeDeviceStatus = "device_status"
+	TypeCommand      = "command"
+	// TypeCurrentReadings carries a contract.CurrentReadingsDTO, the live
+	// view of the room or home a client watches.
+	TypeCurrentReadings = "current_readings"
+)
+
+const (
+	ErrorCodeInvalidMessage  = "invalid_message"
+	ErrorCodeUnsupportedType = "unsupported_type"
+	ErrorCodeForbidden       = "forbidden"
+	ErrorCodeDeviceOffline   = "device_offline"
+	ErrorCodeInternal        = "internal_error"
+)
+
+const (
+	DeviceOnline  = "online"
+	DeviceOffline = "offline"
+)
+
+// Envelope wraps every frame exchanged over the websocket. ID is chosen by
+// the sender and echoed back in the ack or error answering the frame.
+type Envelope struct {
+	Version int             `json:"v" validate:"eq=1"`
+	Type    string          `json:"type" validate:"required,oneof=reading subscribe unsubscribe ack error device_status command current_readings"`
+	ID      string          `json:"id" validate:"max=64"`
+	TS      time.Time       `json:"ts"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ReadingPayload carries any set of known metrics keyed by name. Sensors
+// that only measure temperature may send the temperature and unit fields
+// instead of metrics.
+type ReadingPayload struct {
+	DeviceID    uuid.UUID                          `json:"device_id"`
+	Temperature *float64                           `json:"temperature,omitempty"`
+	Unit        string                             `json:"unit,omitempty"`
+	Metrics     map[string]contract.MetricValueDTO `json:"metrics,omitempty"`
+	RecordedAt  time.Time                          `json:"recorded_at"`
+}
+
+// metrics merges the temperature shorthand into the metrics map.
+func (p ReadingPayload) metrics() map[string]contract.MetricValueDTO {
+	metrics := make(map[string]contract.MetricValueDTO, len(p.Metrics)+1)
+
+	if p.Temperature != nil {
+		metrics[domain.MetricTemperature] = contract.MetricValueDTO{Value: *p.Temperature, Unit: p.Unit}
+	}
+	for metric, value := range p.Metrics {
+		metrics[metric] = value
+	}
+
+	return metrics
+}
+
+// inUnit returns a copy of a stored reading with its temperature, kept in
+// Celsius, converted to unit.
+func (p ReadingPayload) inUnit(unit string) ReadingPayload {
+	if !domain.IsTemperatureUnit(unit) || unit == domain.UnitCelsius {
+		return p
+	}
+
+	converted := p
+	converted.Metrics = make(map[string]contract.MetricValueDTO, len(p.Metrics))
+
+	for metric, value := range p.Metrics {
+		if metric == domain.MetricTemperature {
+			value = contract.MetricValueDTO{Value: domain.FromCelsius(value.Value, unit), Unit: unit}
+		}
+		converted.Metrics[metric] = value
+	}
+
+	if p.Temperature != nil {
+		temperature := domain.FromCelsius(*p.Temperature, unit)
+		converted.Temperature = &temperature
+		converted.Unit = unit
+	}
+
+	return converted
+}
+
+type SubscriptionPayload struct {
+	DeviceIDs []uuid.UUID `json:"device_ids" validate:"required,min=1,max=100"`
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type DeviceStatusPayload struct {
+	DeviceID uuid.UUID `json:"device_id"`
+	Status   string    `json:"status"`
+}
+
+type CommandPayload struct {
+	DeviceID uuid.UUID       `json:"device_id" validate:"required"`
+	Name     string          `json:"name" validate:"required,max=50"`
+	Params   json.RawMessage `json:"params,omitempty"`
+}
+
+// NewEnvelope builds an envelope of the current protocol version stamped
+// with the current time.
+func NewEnvelope(kind, id string, payload interface{}) (Envelope, error) {
+	envelope := Envelope{
+		Version: ProtocolVersion,
+		Type:    kind,
+		ID:      id,
+		TS:      time.Now().UTC(),
+	}
+
+	if payload != nil {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return Envelope{}, err
+		}
+		envelope.Payload = raw
+	}
+
+	return envelope, nil
 }
@@ -1,15 +1,22 @@
 package pkg
 
 import (
-	"errors"
-	"time"
-
-	"github.com/go-chi/jwtauth/v5"
 	"github.com/go-playground/validator/v10"
-	"github.com/google/uuid"
 )
 
-var tokenAuth = jwtauth.New("HS256", []byte("secretKey"), nil)
+// InputError is an error caused by the request, its message is meant to
+// be shown to the client. ValidateStruct returns them.
+type InputError struct {
+	message string
+}
+
+func NewInputError(message string) error {
+	return &InputError{message: message}
+}
+
+func (e *InputError) Error() string {
+	return e.message
+}
 
 func ValidateStruct(data interface{}) error {
 	validate := validator.New()
@@ -24,26 +31,18 @@ func ValidateStruct(data interface{}) error {
 
 	switch validationError.Tag() {
 	case "required":
-		return errors.New(validationError.StructField() + " is required")
+		return NewInputError(validationError.StructField() + " is required")
 	case "max":
-		return errors.New(validationError.StructField() + " is required with max: " + validationError.Param())
+		return NewInputError(validationError.StructField() + " is required with max: " + validationError.Param())
 	case "min":
-		return errors.New(validationError.StructField() + " is required with min: " + validationError.Param())
-	case "email":
-		return errors.New(validationError.StructField() + " is invalid.")
+		return NewInputError(validationError.StructField() + " is required with min: " + validationError.Param())
+	case "email", "eq":
+		return NewInputError(validationError.StructField() + " is invalid.")
+	case "oneof":
+		return NewInputError(validationError.StructField() + " must be one of: " + validationError.Param())
+	case "gtfield":
+		return NewInputError(validationError.StructField() + " must be after " + validationError.Param())
 	}
 
 	return nil
 }
-
-func GenerateJWT(userID uuid.UUID) (string, error) {
-	_, tokenString, err := tokenAuth.Encode(map[string]interface{}{
-		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 8).Unix(),
-	})
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
-}